@@ -0,0 +1,189 @@
+// Package apperr defines the typed errors controllers return and writes them
+// as RFC 7807 application/problem+json responses. Internal causes are logged
+// with the request ID and never sent to the client.
+package apperr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mdarify1337/backend-go/backend/requestid"
+)
+
+// Kind classifies an error and fixes its status, title and type URI.
+type Kind string
+
+const (
+	KindBadRequest   Kind = "bad-request"
+	KindValidation   Kind = "validation-failed"
+	KindUnauthorized Kind = "unauthorized"
+	KindForbidden    Kind = "forbidden"
+	KindNotFound     Kind = "not-found"
+	KindMethod       Kind = "method-not-allowed"
+	KindConflict     Kind = "conflict"
+	KindTooLarge     Kind = "payload-too-large"
+	KindInternal     Kind = "internal"
+	KindTimeout      Kind = "timeout"
+)
+
+// StatusClientClosedRequest is the non-standard status recorded when the
+// client went away before the response was ready.
+const StatusClientClosedRequest = 499
+
+// TypeBase prefixes every problem type URI. The URIs are part of the API
+// contract: clients switch on them, so they must not change.
+const TypeBase = "/problems/"
+
+var kinds = map[Kind]struct {
+	status int
+	title  string
+}{
+	KindBadRequest:   {http.StatusBadRequest, "Bad request"},
+	KindValidation:   {http.StatusUnprocessableEntity, "Validation failed"},
+	KindUnauthorized: {http.StatusUnauthorized, "Unauthorized"},
+	KindForbidden:    {http.StatusForbidden, "Forbidden"},
+	KindNotFound:     {http.StatusNotFound, "Not found"},
+	KindMethod:       {http.StatusMethodNotAllowed, "Method not allowed"},
+	KindConflict:     {http.StatusConflict, "Conflict"},
+	KindTooLarge:     {http.StatusRequestEntityTooLarge, "Payload too large"},
+	KindInternal:     {http.StatusInternalServerError, "Internal server error"},
+	KindTimeout:      {http.StatusGatewayTimeout, "Gateway timeout"},
+}
+
+// Error is a domain error with a client-safe detail and an optional
+// internal cause.
+type Error struct {
+	Kind   Kind
+	Detail string
+	Errors []FieldError
+	Err    error
+}
+
+// FieldError points at one invalid member of the request body, or at an
+// invalid query parameter.
+type FieldError struct {
+	Pointer   string `json:"pointer,omitempty"` // RFC 6901 JSON pointer, e.g. /email
+	Parameter string `json:"parameter,omitempty"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Detail)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Status is the HTTP status the error maps to.
+func (e *Error) Status() int { return kinds[e.Kind].status }
+
+func BadRequest(detail string) *Error   { return &Error{Kind: KindBadRequest, Detail: detail} }
+func Unauthorized(detail string) *Error { return &Error{Kind: KindUnauthorized, Detail: detail} }
+func Forbidden(detail string) *Error    { return &Error{Kind: KindForbidden, Detail: detail} }
+func NotFound(detail string) *Error     { return &Error{Kind: KindNotFound, Detail: detail} }
+func Conflict(detail string) *Error     { return &Error{Kind: KindConflict, Detail: detail} }
+func Validation(detail string) *Error   { return &Error{Kind: KindValidation, Detail: detail} }
+
+// Invalid reports every field that failed validation at once.
+func Invalid(errs []FieldError) *Error {
+	detail := "The request has 1 invalid field"
+	if len(errs) != 1 {
+		detail = fmt.Sprintf("The request has %d invalid fields", len(errs))
+	}
+	return &Error{Kind: KindValidation, Detail: detail, Errors: errs}
+}
+
+// TooLarge rejects a request body longer than limit bytes.
+func TooLarge(limit int64) *Error {
+	return &Error{Kind: KindTooLarge, Detail: fmt.Sprintf("The request body must not exceed %d bytes", limit)}
+}
+
+func MethodNotAllowed(detail string) *Error {
+	return &Error{Kind: KindMethod, Detail: detail}
+}
+
+// Internal wraps an unexpected failure. Only a generic message reaches the
+// client; err is logged.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Detail: "An unexpected error occurred", Err: err}
+}
+
+// Timeout wraps an operation that ran out of time. Like Internal, only a
+// generic message reaches the client.
+func Timeout(err error) *Error {
+	return &Error{Kind: KindTimeout, Detail: "The request took too long to complete", Err: err}
+}
+
+// Problem is the RFC 7807 response body.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	TraceID   string       `json:"trace_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// Write sends err as application/problem+json. Errors that are not an *Error
+// are treated as internal, except that a deadline becomes a 504, a body cut
+// off by http.MaxBytesReader a 413, and a client that hung up only gets
+// logged.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	reqID := requestid.From(r.Context())
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		slog.InfoContext(r.Context(), "client closed request", "method", r.Method,
+			"path", r.URL.Path, "status", StatusClientClosedRequest)
+		w.WriteHeader(StatusClientClosedRequest)
+		return
+	}
+
+	var e *Error
+	var tooLarge *http.MaxBytesError
+	if errors.Is(err, context.DeadlineExceeded) {
+		e = Timeout(err)
+	} else if errors.As(err, &tooLarge) {
+		e = TooLarge(tooLarge.Limit)
+	} else if !errors.As(err, &e) {
+		e = Internal(err)
+	}
+	kind, ok := kinds[e.Kind]
+	if !ok {
+		e, kind = Internal(err), kinds[KindInternal]
+	}
+
+	if e.Kind == KindInternal || e.Kind == KindTimeout {
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path,
+			"kind", e.Kind, "err", e.Err)
+	}
+
+	p := Problem{
+		Type:      TypeBase + string(e.Kind),
+		Title:     kind.title,
+		Status:    kind.status,
+		Detail:    e.Detail,
+		Instance:  r.URL.Path,
+		RequestID: reqID,
+		Errors:    e.Errors,
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		p.TraceID = sc.TraceID().String()
+	}
+	if e.Kind == KindUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="backend-go"`)
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+)
+
+// Config describes how tokens are signed and how long they live.
+//
+// Keys is a comma separated list of kid:base64 pairs. For HS256 the value is
+// the shared secret, for EdDSA the 32 byte ed25519 seed. Old keys stay in the
+// list after rotation so tokens they signed keep verifying until expiry.
+type Config struct {
+	Issuer     string
+	Alg        string
+	Keys       string
+	ActiveKID  string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Ephemeral allows an empty Keys, signing with a random key per
+	// process. Only a single development instance can work that way.
+	Ephemeral bool
+	// DB bounds the queries of the refresh token and role stores.
+	DB dbctx.Timeouts
+}
+
+// ErrNoKeys is returned by ParseKeys when no keys are configured and
+// ephemeral ones are not allowed.
+var ErrNoKeys = errors.New("auth: no signing keys configured; set JWT_KEYS, or DEV_MODE for a throwaway key")
+
+// ParseKeys decodes cfg.Keys. With no keys configured it generates a random
+// one if cfg.Ephemeral allows it and fails otherwise: a random key logs
+// everyone out on restart and is not shared between replicas.
+func (cfg Config) ParseKeys() ([]Key, string, error) {
+	if cfg.Keys == "" {
+		if !cfg.Ephemeral {
+			return nil, "", ErrNoKeys
+		}
+		slog.Warn("no JWT_KEYS configured, using an ephemeral key")
+		seed := make([]byte, 32)
+		if _, err := rand.Read(seed); err != nil {
+			return nil, "", err
+		}
+		key, err := newKey("ephemeral", cfg.Alg, seed)
+		return []Key{key}, key.ID, err
+	}
+
+	var keys []Key
+	for _, pair := range strings.Split(cfg.Keys, ",") {
+		kid, value, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok || kid == "" {
+			return nil, "", fmt.Errorf("auth: malformed key entry %q, want kid:base64", pair)
+		}
+		raw, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, "", fmt.Errorf("auth: key %q is not valid base64: %w", kid, err)
+		}
+		key, err := newKey(kid, cfg.Alg, raw)
+		if err != nil {
+			return nil, "", err
+		}
+		keys = append(keys, key)
+	}
+	active := cfg.ActiveKID
+	if active == "" {
+		active = keys[0].ID
+	}
+	return keys, active, nil
+}
+
+func newKey(kid, alg string, raw []byte) (Key, error) {
+	switch alg {
+	case AlgHS256:
+		return Key{ID: kid, Alg: alg, Secret: raw}, nil
+	case AlgEdDSA:
+		if len(raw) != ed25519.SeedSize {
+			return Key{}, fmt.Errorf("auth: EdDSA key %q must be a %d byte seed", kid, ed25519.SeedSize)
+		}
+		priv := ed25519.NewKeyFromSeed(raw)
+		return Key{ID: kid, Alg: alg, PrivateKey: priv,
+			PublicKey: priv.Public().(ed25519.PublicKey)}, nil
+	default:
+		return Key{}, fmt.Errorf("auth: unsupported JWT_ALG %q", alg)
+	}
+}
@@ -0,0 +1,16 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseKeysWithoutKeys(t *testing.T) {
+	if _, _, err := (Config{Alg: AlgHS256}).ParseKeys(); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("err = %v, want ErrNoKeys", err)
+	}
+	keys, active, err := (Config{Alg: AlgHS256, Ephemeral: true}).ParseKeys()
+	if err != nil || len(keys) != 1 || active != keys[0].ID {
+		t.Errorf("ephemeral: keys = %v, active = %q, err = %v", keys, active, err)
+	}
+}
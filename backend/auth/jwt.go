@@ -0,0 +1,192 @@
+// Package auth issues and verifies the tokens that make up a session: short
+// lived signed access tokens (JWT) and opaque, rotating refresh tokens.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrInvalidToken = errors.New("auth: invalid token")
+	ErrExpiredToken = errors.New("auth: token expired")
+)
+
+// Key is one signing key. HS256 keys carry Secret, EdDSA keys carry the
+// ed25519 pair; PrivateKey may be nil on instances that only verify.
+type Key struct {
+	ID         string
+	Alg        string
+	Secret     []byte
+	PrivateKey ed25519.PrivateKey
+	PublicKey  ed25519.PublicKey
+}
+
+// Claims is the payload of an access token.
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	ID        string   `json:"jti"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// UserID returns the numeric user ID held in the subject.
+func (c Claims) UserID() (int, error) {
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return id, nil
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// Issuer signs access tokens with its active key and verifies tokens signed
+// by any key it knows, so keys can be rotated without logging users out.
+type Issuer struct {
+	name   string
+	ttl    time.Duration
+	active string
+	keys   map[string]Key
+	now    func() time.Time
+}
+
+// NewIssuer returns an Issuer that signs with the key whose ID is active.
+// Every key is checked, not only the active one: HS256 secrets need 32
+// bytes and EdDSA keys a public key.
+func NewIssuer(name string, ttl time.Duration, active string, keys []Key) (*Issuer, error) {
+	iss := &Issuer{name: name, ttl: ttl, active: active, keys: map[string]Key{}, now: time.Now}
+	for _, k := range keys {
+		if k.Alg != AlgHS256 && k.Alg != AlgEdDSA {
+			return nil, fmt.Errorf("auth: key %q has unsupported algorithm %q", k.ID, k.Alg)
+		}
+		if _, dup := iss.keys[k.ID]; dup {
+			return nil, fmt.Errorf("auth: duplicate key id %q", k.ID)
+		}
+		// Retired keys still verify, so a weak one is as bad as a weak
+		// active key
+		if k.Alg == AlgHS256 && len(k.Secret) < 32 {
+			return nil, fmt.Errorf("auth: HS256 key %q must be at least 32 bytes", k.ID)
+		}
+		if k.Alg == AlgEdDSA && len(k.PublicKey) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("auth: EdDSA key %q has no public key", k.ID)
+		}
+		iss.keys[k.ID] = k
+	}
+	k, ok := iss.keys[active]
+	if !ok {
+		return nil, fmt.Errorf("auth: active key %q not found", active)
+	}
+	if k.Alg == AlgEdDSA && len(k.PrivateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("auth: EdDSA key %q has no private key", k.ID)
+	}
+	return iss, nil
+}
+
+// TTL is the lifetime of the access tokens this Issuer signs.
+func (iss *Issuer) TTL() time.Duration { return iss.ttl }
+
+// Sign returns a new access token for userID holding roles, and its expiry.
+func (iss *Issuer) Sign(userID int, roles []string) (string, time.Time, error) {
+	key := iss.keys[iss.active]
+	now := iss.now()
+	exp := now.Add(iss.ttl)
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", time.Time{}, err
+	}
+	h, _ := json.Marshal(header{Alg: key.Alg, Typ: "JWT", Kid: key.ID})
+	c, _ := json.Marshal(Claims{
+		Issuer:    iss.name,
+		Subject:   strconv.Itoa(userID),
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: exp.Unix(),
+		Roles:     roles,
+	})
+	signingInput := b64.EncodeToString(h) + "." + b64.EncodeToString(c)
+	return signingInput + "." + b64.EncodeToString(sign(key, signingInput)), exp, nil
+}
+
+// Verify checks the signature, algorithm and expiry of token and returns
+// its claims.
+func (iss *Issuer) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	key, ok := iss.keys[h.Kid]
+	// The algorithm is pinned by the key, never taken from the token alone
+	if !ok || h.Alg != key.Alg {
+		return Claims{}, ErrInvalidToken
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !verify(key, parts[0]+"."+parts[1], sig) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if c.Issuer != iss.name {
+		return Claims{}, ErrInvalidToken
+	}
+	if iss.now().Unix() >= c.ExpiresAt {
+		return Claims{}, ErrExpiredToken
+	}
+	return c, nil
+}
+
+func sign(key Key, input string) []byte {
+	if key.Alg == AlgEdDSA {
+		return ed25519.Sign(key.PrivateKey, []byte(input))
+	}
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(input))
+	return mac.Sum(nil)
+}
+
+func verify(key Key, input string, sig []byte) bool {
+	if key.Alg == AlgEdDSA {
+		return len(key.PublicKey) == ed25519.PublicKeySize &&
+			ed25519.Verify(key.PublicKey, []byte(input), sig)
+	}
+	return hmac.Equal(sign(key, input), sig)
+}
+
+func decodeSegment(seg string, v any) error {
+	raw, err := b64.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
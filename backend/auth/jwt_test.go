@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewIssuerChecksEveryKey(t *testing.T) {
+	strong := Key{ID: "new", Alg: AlgHS256, Secret: []byte(strings.Repeat("s", 32))}
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	ed := Key{ID: "ed", Alg: AlgEdDSA, PrivateKey: priv, PublicKey: priv.Public().(ed25519.PublicKey)}
+
+	tests := []struct {
+		name   string
+		active string
+		keys   []Key
+		want   string
+	}{
+		{"strong keys", "new", []Key{strong, ed}, ""},
+		{"short active secret", "old", []Key{{ID: "old", Alg: AlgHS256, Secret: []byte("short")}}, `"old" must be at least 32 bytes`},
+		{"short retired secret", "new", []Key{strong, {ID: "old", Alg: AlgHS256, Secret: []byte("short")}}, `"old" must be at least 32 bytes`},
+		{"verify-only EdDSA key", "new", []Key{strong, {ID: "pub", Alg: AlgEdDSA, PublicKey: ed.PublicKey}}, ""},
+		{"EdDSA key without public key", "new", []Key{strong, {ID: "priv", Alg: AlgEdDSA, PrivateKey: priv}}, `"priv" has no public key`},
+		{"active EdDSA key without private key", "pub", []Key{{ID: "pub", Alg: AlgEdDSA, PublicKey: ed.PublicKey}}, `"pub" has no private key`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewIssuer("test", time.Minute, tt.active, tt.keys)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("err = %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Errorf("err = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryRefreshStore keeps refresh tokens in memory with the same rotation
+// and reuse detection as RefreshStore, so handlers can run under httptest
+// without a database.
+type MemoryRefreshStore struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]memRefresh // by token hash
+}
+
+type memRefresh struct {
+	userID    int
+	family    string
+	expiresAt time.Time
+	revoked   bool
+}
+
+// NewMemoryRefreshStore returns an empty store issuing tokens valid for ttl.
+func NewMemoryRefreshStore(ttl time.Duration) *MemoryRefreshStore {
+	return &MemoryRefreshStore{ttl: ttl, tokens: map[string]memRefresh{}}
+}
+
+func (s *MemoryRefreshStore) Issue(ctx context.Context, userID int) (string, error) {
+	family, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.insert(userID, family)
+}
+
+func (s *MemoryRefreshStore) Rotate(ctx context.Context, token string) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[hashToken(token)]
+	if !ok {
+		return "", 0, ErrInvalidRefresh
+	}
+	if t.revoked {
+		s.revokeFamily(t.family)
+		return "", 0, ErrRefreshReused
+	}
+	if time.Now().After(t.expiresAt) {
+		return "", 0, ErrInvalidRefresh
+	}
+	next, err := s.insert(t.userID, t.family)
+	if err != nil {
+		return "", 0, err
+	}
+	t.revoked = true
+	s.tokens[hashToken(token)] = t
+	return next, t.userID, nil
+}
+
+func (s *MemoryRefreshStore) Revoke(ctx context.Context, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tokens[hashToken(token)]; ok {
+		s.revokeFamily(t.family)
+	}
+	return nil
+}
+
+func (s *MemoryRefreshStore) insert(userID int, family string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	s.tokens[hashToken(token)] = memRefresh{userID: userID, family: family,
+		expiresAt: time.Now().Add(s.ttl)}
+	return token, nil
+}
+
+func (s *MemoryRefreshStore) revokeFamily(family string) {
+	for hash, t := range s.tokens {
+		if t.family == family {
+			t.revoked = true
+			s.tokens[hash] = t
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/httpx"
+)
+
+// RoleAdmin is the role that holds every permission.
+const RoleAdmin = "admin"
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	UserID  int
+	Roles   []string
+	TokenID string
+}
+
+// HasRole reports whether the principal holds role.
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin reports whether the principal holds the admin role.
+func (p Principal) IsAdmin() bool { return p.HasRole(RoleAdmin) }
+
+// Can reports whether the principal's roles grant perm.
+func (p Principal) Can(perm string) bool { return Can(p.Roles, perm) }
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+type authFailureKey struct{}
+
+// AuthFailure returns why the bearer token sent with the request was
+// rejected, or "" when none was sent or it was valid.
+func AuthFailure(ctx context.Context) string {
+	msg, _ := ctx.Value(authFailureKey{}).(string)
+	return msg
+}
+
+// Authenticate validates a bearer token when one is sent and stores the
+// resulting Principal in the request context. Requests without a token, or
+// with one that fails to verify, pass through anonymously; whether that is
+// allowed is up to Require, which reports the recorded failure as a 401 on
+// routes that are not Public.
+func (iss *Issuer) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reject := func(msg string, err error) {
+			slog.DebugContext(r.Context(), "bearer token rejected", "reason", msg, "error", err)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authFailureKey{}, msg)))
+		}
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			reject("Malformed Authorization header", nil)
+			return
+		}
+		claims, err := iss.Verify(strings.TrimSpace(token))
+		if err != nil {
+			reject("Invalid or expired token", err)
+			return
+		}
+		userID, err := claims.UserID()
+		if err != nil {
+			reject("Invalid or expired token", err)
+			return
+		}
+		p := Principal{UserID: userID, Roles: claims.Roles, TokenID: claims.ID}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+	})
+}
+
+// OwnerFunc extracts the ID of the user owning the resource a request
+// targets.
+type OwnerFunc func(r *http.Request) (int, error)
+
+// Requirement is the access rule a route declares.
+type Requirement struct {
+	name  string
+	check func(r *http.Request, p Principal, authenticated bool) (int, string)
+}
+
+var (
+	// Public lets anyone through.
+	Public = Requirement{"public", func(*http.Request, Principal, bool) (int, string) {
+		return 0, ""
+	}}
+
+	// Authenticated requires a valid access token.
+	Authenticated = Requirement{"authenticated", func(_ *http.Request, _ Principal, ok bool) (int, string) {
+		if !ok {
+			return http.StatusUnauthorized, "Authentication required"
+		}
+		return 0, ""
+	}}
+
+	// Admin requires the admin role.
+	Admin = Requirement{"admin", func(_ *http.Request, p Principal, ok bool) (int, string) {
+		if !ok {
+			return http.StatusUnauthorized, "Authentication required"
+		}
+		if !p.IsAdmin() {
+			return http.StatusForbidden, "Admin role required"
+		}
+		return 0, ""
+	}}
+)
+
+// Permission requires a role granting perm.
+func Permission(perm string) Requirement {
+	return Requirement{perm, func(_ *http.Request, p Principal, ok bool) (int, string) {
+		if !ok {
+			return http.StatusUnauthorized, "Authentication required"
+		}
+		if !p.Can(perm) {
+			return http.StatusForbidden, "Missing permission " + perm
+		}
+		return 0, ""
+	}}
+}
+
+// OwnerOr requires the caller to be the user owner returns, or to hold perm.
+func OwnerOr(perm string, owner OwnerFunc) Requirement {
+	return Requirement{"owner or " + perm, func(r *http.Request, p Principal, ok bool) (int, string) {
+		if !ok {
+			return http.StatusUnauthorized, "Authentication required"
+		}
+		if p.Can(perm) {
+			return 0, ""
+		}
+		id, err := owner(r)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, apperr.TooLarge(tooLarge.Limit).Detail
+		} else if err != nil {
+			return http.StatusBadRequest, err.Error()
+		}
+		if id != p.UserID {
+			return http.StatusForbidden, "You do not own this resource"
+		}
+		return 0, ""
+	}}
+}
+
+// Require wraps next so it only runs when the request satisfies req.
+func Require(req Requirement, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := PrincipalFrom(r.Context())
+		status, msg := req.check(r, p, ok)
+		switch status {
+		case 0:
+			next(w, r)
+		case http.StatusUnauthorized:
+			if failure := AuthFailure(r.Context()); failure != "" {
+				msg = failure
+			}
+			apperr.Write(w, r, apperr.Unauthorized(msg))
+		case http.StatusForbidden:
+			slog.WarnContext(r.Context(), "access denied", "method", r.Method,
+				"path", r.URL.Path, "requirement", req.name, "reason", msg)
+			apperr.Write(w, r, apperr.Forbidden(msg))
+		case http.StatusRequestEntityTooLarge:
+			apperr.Write(w, r, &apperr.Error{Kind: apperr.KindTooLarge, Detail: msg})
+		default:
+			apperr.Write(w, r, apperr.BadRequest(msg))
+		}
+	}
+}
+
+// OwnerFromQuery reads the owner ID from the named query parameter.
+func OwnerFromQuery(param string) OwnerFunc {
+	return func(r *http.Request) (int, error) {
+		id, err := strconv.Atoi(r.URL.Query().Get(param))
+		if err != nil {
+			return 0, errors.New("Invalid user ID")
+		}
+		return id, nil
+	}
+}
+
+// OwnerFromPath reads the owner ID from the named wildcard of the matched
+// ServeMux pattern, as in /v1/users/{id}.
+func OwnerFromPath(name string) OwnerFunc {
+	return func(r *http.Request) (int, error) {
+		id, err := strconv.Atoi(r.PathValue(name))
+		if err != nil {
+			return 0, errors.New("Invalid user ID")
+		}
+		return id, nil
+	}
+}
+
+// OwnerFromBody reads the owner ID from the named field of a JSON body and
+// leaves the body intact for the handler. A body over httpx.MaxBodyBytes
+// fails with the *http.MaxBytesError.
+func OwnerFromBody(field string) OwnerFunc {
+	return func(r *http.Request) (int, error) {
+		r.Body = http.MaxBytesReader(nil, r.Body, httpx.MaxBodyBytes)
+		body, err := io.ReadAll(r.Body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return 0, err
+		} else if err != nil {
+			return 0, errors.New("Invalid request payload")
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return 0, errors.New("Invalid request payload")
+		}
+		var id int
+		if err := json.Unmarshal(fields[field], &id); err != nil {
+			return 0, errors.New("Invalid user ID")
+		}
+		return id, nil
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testIssuer(t *testing.T) *Issuer {
+	t.Helper()
+	iss, err := NewIssuer("test", time.Minute, "k1", []Key{
+		{ID: "k1", Alg: AlgHS256, Secret: []byte(strings.Repeat("s", 32))},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return iss
+}
+
+func expiredToken(t *testing.T, iss *Issuer) string {
+	t.Helper()
+	iss.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	defer func() { iss.now = time.Now }()
+	token, _, err := iss.Sign(7, []string{RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestAuthenticateBadTokens(t *testing.T) {
+	iss := testIssuer(t)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /public", Require(Public, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := PrincipalFrom(r.Context()); ok {
+			t.Error("rejected token produced a principal")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	mux.HandleFunc("GET /private", Require(Authenticated, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler := iss.Authenticate(mux)
+
+	tests := []struct {
+		name   string
+		header string
+		path   string
+		status int
+		detail string
+	}{
+		{"expired on public", "Bearer " + expiredToken(t, iss), "/public", http.StatusNoContent, ""},
+		{"garbage on public", "Bearer not.a.jwt", "/public", http.StatusNoContent, ""},
+		{"malformed on public", "Basic abc", "/public", http.StatusNoContent, ""},
+		{"expired on private", "Bearer " + expiredToken(t, iss), "/private", http.StatusUnauthorized, "Invalid or expired token"},
+		{"malformed on private", "Basic abc", "/private", http.StatusUnauthorized, "Malformed Authorization header"},
+		{"missing on private", "", "/private", http.StatusUnauthorized, "Authentication required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodGet
+			if tt.path == "/public" {
+				method = http.MethodPost
+			}
+			r := httptest.NewRequest(method, tt.path, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.detail == "" {
+				return
+			}
+			var problem struct {
+				Detail string `json:"detail"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&problem); err != nil {
+				t.Fatal(err)
+			}
+			if problem.Detail != tt.detail {
+				t.Errorf("detail = %q, want %q", problem.Detail, tt.detail)
+			}
+		})
+	}
+}
+
+func TestAuthenticateValidToken(t *testing.T) {
+	iss := testIssuer(t)
+	token, _, err := iss.Sign(7, []string{RoleCustomer})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Principal
+	handler := iss.Authenticate(Require(Authenticated, func(w http.ResponseWriter, r *http.Request) {
+		got, _ = PrincipalFrom(r.Context())
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK || got.UserID != 7 {
+		t.Fatalf("status = %d, principal = %+v", w.Code, got)
+	}
+}
+
+func TestRequireOptions(t *testing.T) {
+	served := false
+	handler := Require(Permission(PermRolesManage), func(http.ResponseWriter, *http.Request) { served = true })
+
+	tests := []struct {
+		name   string
+		roles  []string
+		status int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"customer", []string{RoleCustomer}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodOptions, "/v1/users/7/roles", nil)
+			if tt.roles != nil {
+				r = r.WithContext(WithPrincipal(r.Context(), Principal{UserID: 7, Roles: tt.roles}))
+			}
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if served || w.Code != tt.status {
+				t.Errorf("served = %v, status = %d, want %d", served, w.Code, tt.status)
+			}
+		})
+	}
+}
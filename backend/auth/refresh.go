@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+)
+
+var (
+	ErrInvalidRefresh = errors.New("auth: invalid refresh token")
+	ErrRefreshReused  = errors.New("auth: refresh token reuse detected")
+)
+
+// RefreshRepository issues, rotates and revokes refresh tokens.
+type RefreshRepository interface {
+	// Issue starts a new token family for userID and returns its first
+	// token.
+	Issue(ctx context.Context, userID int) (string, error)
+	// Rotate exchanges token for a new one in the same family and returns
+	// the new token with the user it belongs to. An unknown or expired
+	// token fails with ErrInvalidRefresh, an already rotated one revokes
+	// its family and fails with ErrRefreshReused.
+	Rotate(ctx context.Context, token string) (string, int, error)
+	// Revoke ends the session token belongs to. Unknown tokens are ignored.
+	Revoke(ctx context.Context, token string) error
+}
+
+// RefreshStore keeps refresh tokens in the refresh_tokens table. Only the
+// SHA-256 of each token is stored. Every rotation links the new token to the
+// old one through a shared family, so presenting an already rotated token
+// revokes the whole family.
+type RefreshStore struct {
+	db       *sql.DB
+	ttl      time.Duration
+	timeouts dbctx.Timeouts
+}
+
+// NewRefreshStore returns a store issuing tokens valid for ttl, each
+// operation bounded by timeouts.
+func NewRefreshStore(db *sql.DB, ttl time.Duration, timeouts dbctx.Timeouts) *RefreshStore {
+	return &RefreshStore{db: db, ttl: ttl, timeouts: timeouts}
+}
+
+// Issue starts a new token family for userID and returns its first token.
+func (s *RefreshStore) Issue(ctx context.Context, userID int) (_ string, err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "refresh_tokens.issue")
+	defer done(&err)
+
+	family, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	token, _, err := s.insert(ctx, s.db, userID, family)
+	return token, err
+}
+
+// Rotate exchanges token for a new one in the same family and returns the
+// new token with the user it belongs to.
+func (s *RefreshStore) Rotate(ctx context.Context, token string) (_ string, _ int, err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "refresh_tokens.rotate")
+	defer done(&err)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var (
+		id, userID int
+		family     string
+		expiresAt  time.Time
+		revokedAt  sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, user_id, family_id, expires_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE;
+	`, hashToken(token)).Scan(&id, &userID, &family, &expiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return "", 0, ErrInvalidRefresh
+	} else if err != nil {
+		return "", 0, fmt.Errorf("failed to load refresh token: %w", err)
+	}
+
+	if revokedAt.Valid {
+		// A rotated token came back: someone holds a stolen copy
+		if err := revokeFamily(ctx, tx, family); err != nil {
+			return "", 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", 0, err
+		}
+		slog.WarnContext(ctx, "refresh token reused, family revoked", "user_id", userID)
+		return "", 0, ErrRefreshReused
+	}
+	if time.Now().After(expiresAt) {
+		return "", 0, ErrInvalidRefresh
+	}
+
+	next, nextID, err := s.insert(ctx, tx, userID, family)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now(), replaced_by = $1 WHERE id = $2;
+	`, nextID, id); err != nil {
+		return "", 0, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return "", 0, err
+	}
+	return next, userID, nil
+}
+
+// Revoke ends the session token belongs to by revoking its whole family.
+// Unknown tokens are ignored.
+func (s *RefreshStore) Revoke(ctx context.Context, token string) (err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "refresh_tokens.revoke")
+	defer done(&err)
+
+	var family string
+	err = s.db.QueryRowContext(ctx,
+		`SELECT family_id FROM refresh_tokens WHERE token_hash = $1;`,
+		hashToken(token)).Scan(&family)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to load refresh token: %w", err)
+	}
+	return revokeFamily(ctx, s.db, family)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *RefreshStore) insert(ctx context.Context, db execer, userID int, family string) (string, int, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", 0, err
+	}
+	var id int
+	err = db.QueryRowContext(ctx, `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`, userID, family, hashToken(token), time.Now().Add(s.ttl)).Scan(&id)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token, id, nil
+}
+
+func revokeFamily(ctx context.Context, db execer, family string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now()
+		WHERE family_id = $1 AND revoked_at IS NULL;
+	`, family)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+)
+
+const (
+	RoleStaff    = "staff"
+	RoleCustomer = "customer"
+)
+
+// Permissions known to the API.
+const (
+	PermUsersReadAny     = "users:read:any"
+	PermUsersWriteAny    = "users:write:any"
+	PermUsersDelete      = "users:delete"
+	PermProductsCreate   = "products:create"
+	PermProductsWriteAny = "products:write:any"
+	PermProductsDeleted  = "products:read:deleted"
+	PermRolesManage      = "roles:manage"
+)
+
+// Catalogue maps every role to the permissions it grants. Admins hold every
+// permission implicitly.
+var Catalogue = map[string][]string{
+	RoleAdmin:    {"*"},
+	RoleStaff:    {PermProductsCreate, PermProductsWriteAny},
+	RoleCustomer: {PermProductsCreate},
+}
+
+// Can reports whether any of roles grants perm.
+func Can(roles []string, perm string) bool {
+	for _, role := range roles {
+		for _, granted := range Catalogue[role] {
+			if granted == "*" || granted == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	ErrUnknownRole = errors.New("auth: unknown role")
+	ErrUnknownUser = errors.New("auth: unknown user")
+)
+
+// RoleRepository reads and changes the roles granted to users. RoleStore
+// keeps them in Postgres; package repository has an in-memory one that
+// shares its users.
+type RoleRepository interface {
+	// UserRoles returns the roles granted to userID, sorted by name.
+	UserRoles(ctx context.Context, userID int) ([]string, error)
+	// Grant gives role to userID, failing with ErrUnknownRole or
+	// ErrUnknownUser. Granting a role twice is not an error.
+	Grant(ctx context.Context, userID int, role string) error
+	// Revoke takes role away from userID and reports whether it was held.
+	Revoke(ctx context.Context, userID int, role string) (bool, error)
+}
+
+// RoleStore reads and changes the rows of user_roles. Access tokens carry
+// the roles held when they were signed, so a change takes effect at the
+// next sign-in or refresh.
+type RoleStore struct {
+	db       *sql.DB
+	timeouts dbctx.Timeouts
+}
+
+// NewRoleStore returns a RoleStore backed by db, each operation bounded by
+// timeouts.
+func NewRoleStore(db *sql.DB, timeouts dbctx.Timeouts) *RoleStore {
+	return &RoleStore{db: db, timeouts: timeouts}
+}
+
+// UserRoles returns the roles granted to userID.
+func (s *RoleStore) UserRoles(ctx context.Context, userID int) (_ []string, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, "roles.list")
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT role FROM user_roles WHERE user_id = $1 ORDER BY role;`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// Grant gives role to userID. Granting a role twice is not an error.
+func (s *RoleStore) Grant(ctx context.Context, userID int, role string) (err error) {
+	if _, ok := Catalogue[role]; !ok {
+		return ErrUnknownRole
+	}
+	ctx, done := s.timeouts.ForWrite(ctx, "roles.grant")
+	defer done(&err)
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING;
+	`, userID, role)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23503" { // foreign_key_violation
+		return ErrUnknownUser
+	}
+	if err != nil {
+		return fmt.Errorf("failed to grant role: %w", err)
+	}
+	return nil
+}
+
+// Revoke takes role away from userID and reports whether it was held.
+func (s *RoleStore) Revoke(ctx context.Context, userID int, role string) (_ bool, err error) {
+	if _, ok := Catalogue[role]; !ok {
+		return false, ErrUnknownRole
+	}
+	ctx, done := s.timeouts.ForWrite(ctx, "roles.revoke")
+	defer done(&err)
+
+	result, err := s.db.ExecContext(ctx,
+		`DELETE FROM user_roles WHERE user_id = $1 AND role = $2;`, userID, role)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke role: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
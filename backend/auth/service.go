@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Pair is what a client receives when a session starts or is refreshed.
+type Pair struct {
+	AccessToken  string
+	ExpiresAt    time.Time
+	RefreshToken string
+}
+
+// Sessions starts, renews and ends sessions. *Service implements it; the
+// handlers only depend on this interface.
+type Sessions interface {
+	Login(ctx context.Context, userID int) (Pair, error)
+	Renew(ctx context.Context, refreshToken string) (Pair, error)
+	Logout(ctx context.Context, refreshToken string) error
+}
+
+// Service ties access and refresh tokens together.
+type Service struct {
+	Issuer  *Issuer
+	Refresh RefreshRepository
+	Roles   RoleRepository
+}
+
+// NewService builds the token service described by cfg.
+func NewService(db *sql.DB, cfg Config) (*Service, error) {
+	keys, active, err := cfg.ParseKeys()
+	if err != nil {
+		return nil, err
+	}
+	issuer, err := NewIssuer(cfg.Issuer, cfg.AccessTTL, active, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		Issuer:  issuer,
+		Refresh: NewRefreshStore(db, cfg.RefreshTTL, cfg.DB),
+		Roles:   NewRoleStore(db, cfg.DB),
+	}, nil
+}
+
+// Login starts a new session for userID.
+func (s *Service) Login(ctx context.Context, userID int) (Pair, error) {
+	refresh, err := s.Refresh.Issue(ctx, userID)
+	if err != nil {
+		return Pair{}, err
+	}
+	return s.pair(ctx, userID, refresh)
+}
+
+// Renew rotates refreshToken and signs a fresh access token.
+func (s *Service) Renew(ctx context.Context, refreshToken string) (Pair, error) {
+	next, userID, err := s.Refresh.Rotate(ctx, refreshToken)
+	if err != nil {
+		return Pair{}, err
+	}
+	return s.pair(ctx, userID, next)
+}
+
+// Logout revokes the session refreshToken belongs to.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	return s.Refresh.Revoke(ctx, refreshToken)
+}
+
+func (s *Service) pair(ctx context.Context, userID int, refresh string) (Pair, error) {
+	roles, err := s.Roles.UserRoles(ctx, userID)
+	if err != nil {
+		return Pair{}, err
+	}
+	access, exp, err := s.Issuer.Sign(userID, roles)
+	if err != nil {
+		return Pair{}, err
+	}
+	return Pair{AccessToken: access, ExpiresAt: exp, RefreshToken: refresh}, nil
+}
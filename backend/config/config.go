@@ -0,0 +1,222 @@
+// Package config builds the typed configuration of the backend from, in
+// increasing precedence, built-in defaults, an optional YAML file,
+// environment variables and command line flags, and validates it before
+// anything starts.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+	"github.com/mdarify1337/backend-go/backend/logging"
+	"github.com/mdarify1337/backend-go/backend/tracing"
+)
+
+// Config is everything the backend can be configured with. DevMode lets the
+// server start without auth.keys and paging.cursor_secret, on random keys
+// that change with every restart; it only suits a single local instance.
+type Config struct {
+	DevMode  bool
+	Server   Server
+	Database Database
+	Jobs     Jobs
+	Auth     auth.Config
+	Paging   Paging
+	Log      logging.Config
+	Tracing  tracing.Config
+}
+
+// Server configures the HTTP listeners and their lifecycle. AdminPort
+// serves /metrics apart from the public API; 0 turns it off. An empty
+// CORSOrigin turns CORS off, for deployments behind a same-origin proxy.
+type Server struct {
+	Port              int
+	AdminPort         int
+	CORSOrigin        string
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	DrainDelay        time.Duration
+	ShutdownGrace     time.Duration
+	ReadyTimeout      time.Duration
+}
+
+// Addr is the address to listen on.
+func (s Server) Addr() string { return fmt.Sprintf(":%d", s.Port) }
+
+// AdminAddr is the address of the admin listener.
+func (s Server) AdminAddr() string { return fmt.Sprintf(":%d", s.AdminPort) }
+
+// Database says how to reach Postgres and how long single operations may
+// take.
+type Database struct {
+	Host         string
+	Port         int
+	User         string
+	Password     string
+	Name         string
+	SSLMode      string
+	Migrate      bool
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+}
+
+// DSN is the lib/pq connection string. It holds the password, so never log
+// it. Sessions run in UTC so scanned timestamps serialise as RFC 3339 UTC.
+func (d Database) DSN() string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s timezone=UTC",
+		quoteDSN(d.Host), d.Port, quoteDSN(d.User), quoteDSN(d.Password), quoteDSN(d.Name), quoteDSN(d.SSLMode))
+}
+
+// Timeouts bounds single database operations.
+func (d Database) Timeouts() dbctx.Timeouts {
+	return dbctx.Timeouts{Read: d.ReadTimeout, Write: d.WriteTimeout}
+}
+
+// quoteDSN quotes a value for a key=value connection string.
+func quoteDSN(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// Jobs configures background work.
+type Jobs struct {
+	PurgeAfterDays int
+}
+
+// Paging configures list pagination.
+type Paging struct {
+	// CursorSecret signs cursors. It may only be empty in dev mode, which
+	// uses a random key per process instead.
+	CursorSecret string
+}
+
+// Default is the configuration of a local docker-compose setup.
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:              3001,
+			AdminPort:         9091,
+			CORSOrigin:        "http://localhost:3000",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			DrainDelay:        5 * time.Second,
+			ShutdownGrace:     20 * time.Second,
+			ReadyTimeout:      2 * time.Second,
+		},
+		Database: Database{
+			Host:         "localhost",
+			Port:         5432,
+			SSLMode:      "disable",
+			Migrate:      true,
+			ReadTimeout:  3 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		},
+		Jobs: Jobs{PurgeAfterDays: 30},
+		Auth: auth.Config{
+			Issuer:     "backend-go",
+			Alg:        auth.AlgHS256,
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+		},
+		Log:     logging.Config{Format: logging.FormatText, Level: slog.LevelInfo},
+		Tracing: tracing.Config{ServiceName: "backend-go", Exporter: tracing.ExporterNone, File: "traces.jsonl"},
+	}
+}
+
+// Validate reports every invalid setting at once, each prefixed with its
+// file key.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("  %s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+	positive := func(key string, d time.Duration) {
+		check(d > 0, key, "must be positive, got %s", d)
+	}
+	oneOf := func(key, v string, allowed ...string) {
+		for _, a := range allowed {
+			if v == a {
+				return
+			}
+		}
+		check(false, key, "must be one of %s, got %q", strings.Join(allowed, ", "), v)
+	}
+
+	s := c.Server
+	check(s.Port > 0 && s.Port < 65536, "server.port", "must be between 1 and 65535, got %d", s.Port)
+	check(s.AdminPort >= 0 && s.AdminPort < 65536, "server.admin_port",
+		"must be between 0 and 65535, got %d", s.AdminPort)
+	check(s.AdminPort != s.Port, "server.admin_port", "must differ from server.port")
+	if s.CORSOrigin != "*" && s.CORSOrigin != "" {
+		u, err := url.Parse(s.CORSOrigin)
+		check(err == nil && u.Scheme != "" && u.Host != "" && u.Path == "", "server.cors_origin",
+			"must be *, empty or an origin like https://example.com, got %q", s.CORSOrigin)
+	}
+	positive("server.read_header_timeout", s.ReadHeaderTimeout)
+	positive("server.read_timeout", s.ReadTimeout)
+	positive("server.write_timeout", s.WriteTimeout)
+	positive("server.idle_timeout", s.IdleTimeout)
+	check(s.DrainDelay >= 0, "server.drain_delay", "must not be negative, got %s", s.DrainDelay)
+	positive("server.shutdown_grace", s.ShutdownGrace)
+	positive("server.ready_timeout", s.ReadyTimeout)
+
+	d := c.Database
+	check(d.Host != "", "database.host", "is required")
+	check(d.Port > 0 && d.Port < 65536, "database.port", "must be between 1 and 65535, got %d", d.Port)
+	check(d.User != "", "database.user", "is required")
+	check(d.Name != "", "database.name", "is required")
+	oneOf("database.sslmode", d.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	check(d.ReadTimeout >= 0, "database.read_timeout", "must not be negative, got %s", d.ReadTimeout)
+	check(d.WriteTimeout >= 0, "database.write_timeout", "must not be negative, got %s", d.WriteTimeout)
+	check(d.WriteTimeout < s.WriteTimeout, "database.write_timeout",
+		"must be shorter than server.write_timeout (%s) so timeouts reach the client", s.WriteTimeout)
+
+	check(c.Jobs.PurgeAfterDays > 0, "jobs.purge_after_days", "must be at least 1, got %d", c.Jobs.PurgeAfterDays)
+
+	oneOf("auth.alg", c.Auth.Alg, auth.AlgHS256, auth.AlgEdDSA)
+	positive("auth.access_ttl", c.Auth.AccessTTL)
+	positive("auth.refresh_ttl", c.Auth.RefreshTTL)
+
+	oneOf("log.format", c.Log.Format, logging.FormatText, logging.FormatJSON)
+	oneOf("tracing.exporter", c.Tracing.Exporter, tracing.ExporterNone, tracing.ExporterOTLP,
+		tracing.ExporterConsole, tracing.ExporterFile)
+	check(c.Tracing.Exporter != tracing.ExporterFile || c.Tracing.File != "", "tracing.file",
+		"is required when tracing.exporter is file")
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// Print writes the effective configuration as key = value lines, with
+// secrets redacted.
+func (c *Config) Print(w io.Writer) {
+	for _, f := range c.fields() {
+		fmt.Fprintf(w, "%s = %s\n", f.key, f.display())
+	}
+}
+
+// LogValue logs the effective configuration with secrets redacted.
+func (c *Config) LogValue() slog.Value {
+	var attrs []slog.Attr
+	for _, f := range c.fields() {
+		attrs = append(attrs, slog.String(f.key, f.display()))
+	}
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,296 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted stands in for secrets that are set.
+const Redacted = "[REDACTED]"
+
+// field binds one setting to its file key, environment variable and flag.
+// Secrets get no flag, since command lines are visible to other users.
+type field struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	value  value
+}
+
+func (f field) display() string {
+	if f.secret && f.value.String() != "" {
+		return Redacted
+	}
+	return f.value.String()
+}
+
+// value parses and prints one setting, like flag.Value.
+type value interface {
+	Set(string) error
+	String() string
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{"dev_mode", "DEV_MODE", "dev", "run on ephemeral JWT and cursor keys, for local development only", false, boolValue{&c.DevMode}},
+
+		{"server.port", "PORT", "port", "port to listen on", false, intValue{&c.Server.Port}},
+		{"server.admin_port", "ADMIN_PORT", "admin-port", "port serving /metrics, 0 to disable", false, intValue{&c.Server.AdminPort}},
+		{"server.cors_origin", "CORS_ORIGIN", "cors-origin", "origin allowed to call the API from a browser, empty to disable CORS", false, stringValue{&c.Server.CORSOrigin}},
+		{"server.read_header_timeout", "SERVER_READ_HEADER_TIMEOUT", "read-header-timeout", "time allowed to read request headers", false, durationValue{&c.Server.ReadHeaderTimeout}},
+		{"server.read_timeout", "SERVER_READ_TIMEOUT", "read-timeout", "time allowed to read a whole request", false, durationValue{&c.Server.ReadTimeout}},
+		{"server.write_timeout", "SERVER_WRITE_TIMEOUT", "write-timeout", "time allowed to write a response", false, durationValue{&c.Server.WriteTimeout}},
+		{"server.idle_timeout", "SERVER_IDLE_TIMEOUT", "idle-timeout", "how long idle keep-alive connections stay open", false, durationValue{&c.Server.IdleTimeout}},
+		{"server.drain_delay", "SERVER_DRAIN_DELAY", "drain-delay", "how long readiness fails before the server stops accepting", false, durationValue{&c.Server.DrainDelay}},
+		{"server.shutdown_grace", "SERVER_SHUTDOWN_GRACE", "shutdown-grace", "how long in-flight requests get to finish on shutdown", false, durationValue{&c.Server.ShutdownGrace}},
+		{"server.ready_timeout", "SERVER_READY_TIMEOUT", "ready-timeout", "time each readiness check may take", false, durationValue{&c.Server.ReadyTimeout}},
+
+		{"database.host", "DATABASE_HOST", "db-host", "Postgres host", false, stringValue{&c.Database.Host}},
+		{"database.port", "DATABASE_PORT", "db-port", "Postgres port", false, intValue{&c.Database.Port}},
+		{"database.user", "DATABASE_USER", "db-user", "Postgres user", false, stringValue{&c.Database.User}},
+		{"database.password", "DATABASE_PASSWORD", "", "", true, stringValue{&c.Database.Password}},
+		{"database.name", "DATABASE_NAME", "db-name", "Postgres database", false, stringValue{&c.Database.Name}},
+		{"database.sslmode", "DATABASE_SSLMODE", "db-sslmode", "Postgres sslmode", false, stringValue{&c.Database.SSLMode}},
+		{"database.migrate", "DATABASE_MIGRATE", "migrate", "apply pending migrations before serving", false, boolValue{&c.Database.Migrate}},
+		{"database.read_timeout", "DB_READ_TIMEOUT", "db-read-timeout", "time a single read may take, 0 for no limit", false, durationValue{&c.Database.ReadTimeout}},
+		{"database.write_timeout", "DB_WRITE_TIMEOUT", "db-write-timeout", "time a single write may take, 0 for no limit", false, durationValue{&c.Database.WriteTimeout}},
+
+		{"jobs.purge_after_days", "PURGE_AFTER_DAYS", "purge-after-days", "hard-delete products soft-deleted this many days ago", false, intValue{&c.Jobs.PurgeAfterDays}},
+
+		{"auth.issuer", "JWT_ISSUER", "jwt-issuer", "iss claim of access tokens", false, stringValue{&c.Auth.Issuer}},
+		{"auth.alg", "JWT_ALG", "jwt-alg", "signing algorithm, HS256 or EdDSA", false, stringValue{&c.Auth.Alg}},
+		{"auth.keys", "JWT_KEYS", "", "", true, stringValue{&c.Auth.Keys}},
+		{"auth.active_kid", "JWT_ACTIVE_KID", "jwt-active-kid", "key ID new tokens are signed with", false, stringValue{&c.Auth.ActiveKID}},
+		{"auth.access_ttl", "JWT_ACCESS_TTL", "jwt-access-ttl", "lifetime of access tokens", false, durationValue{&c.Auth.AccessTTL}},
+		{"auth.refresh_ttl", "JWT_REFRESH_TTL", "jwt-refresh-ttl", "lifetime of refresh tokens", false, durationValue{&c.Auth.RefreshTTL}},
+
+		{"paging.cursor_secret", "CURSOR_SECRET", "", "", true, stringValue{&c.Paging.CursorSecret}},
+
+		{"log.format", "LOG_FORMAT", "log-format", "log output, text or json", false, stringValue{&c.Log.Format}},
+		{"log.level", "LOG_LEVEL", "log-level", "minimum level: debug, info, warn or error", false, levelValue{&c.Log.Level}},
+
+		{"tracing.service_name", "OTEL_SERVICE_NAME", "service-name", "service name reported in traces", false, stringValue{&c.Tracing.ServiceName}},
+		{"tracing.exporter", "OTEL_TRACES_EXPORTER", "traces-exporter", "where spans go: none, otlp, console or file", false, stringValue{&c.Tracing.Exporter}},
+		{"tracing.file", "OTEL_TRACES_FILE", "traces-file", "file spans are appended to by the file exporter", false, stringValue{&c.Tracing.File}},
+	}
+}
+
+// Load builds the configuration. When fs is not nil it registers a flag per
+// setting plus -config on it and parses args; the file is taken from
+// -config or CONFIG_FILE. Every environment variable can instead be given
+// as NAME_FILE, the path of a file holding the value, as Docker secrets are
+// mounted.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	fields := cfg.fields()
+
+	path := os.Getenv("CONFIG_FILE")
+	set := map[string]string{}
+	if fs != nil {
+		defaults := Default()
+		for i, f := range defaults.fields() {
+			if f.flag == "" {
+				continue
+			}
+			fs.Var(&flagValue{value: fields[i].value, def: f.value.String(), set: set, flag: f.flag},
+				f.flag, f.usage)
+		}
+		fs.Func("config", "YAML file with settings, overriding CONFIG_FILE", func(v string) error {
+			path = v
+			return nil
+		})
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+	}
+
+	if path != "" {
+		if err := loadFile(path, fields); err != nil {
+			return nil, err
+		}
+	}
+	if err := loadEnv(fields); err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		if v, ok := set[f.flag]; ok && f.flag != "" {
+			if err := f.value.Set(v); err != nil {
+				return nil, fmt.Errorf("config: flag -%s: %w", f.flag, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func loadFile(path string, fields []field) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+	values := map[string]string{}
+	flatten("", doc, values)
+
+	known := map[string]field{}
+	for _, f := range fields {
+		known[f.key] = f
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f, ok := known[k]
+		if !ok {
+			return fmt.Errorf("config: %s: unknown setting %q", path, k)
+		}
+		if err := f.value.Set(values[k]); err != nil {
+			return fmt.Errorf("config: %s: %s: %w", path, k, err)
+		}
+	}
+	return nil
+}
+
+// flatten turns nested maps into dotted keys.
+func flatten(prefix string, doc map[string]any, out map[string]string) {
+	for k, v := range doc {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flatten(k, nested, out)
+			continue
+		}
+		if v == nil {
+			out[k] = ""
+		} else {
+			out[k] = fmt.Sprint(v)
+		}
+	}
+}
+
+// loadEnv applies every variable that is set, even to the empty string:
+// CORS_ORIGIN= clears the origin from the file, turning CORS off, and PORT=
+// is an error rather than a silent fallback.
+func loadEnv(fields []field) error {
+	for _, f := range fields {
+		v, inline := os.LookupEnv(f.env)
+		file, fromFile := os.LookupEnv(f.env + "_FILE")
+		switch {
+		case inline && fromFile:
+			return fmt.Errorf("config: set %s or %s_FILE, not both", f.env, f.env)
+		case fromFile:
+			raw, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("config: %s_FILE: %w", f.env, err)
+			}
+			v = strings.TrimRight(string(raw), "\r\n")
+		case !inline:
+			continue
+		}
+		if err := f.value.Set(v); err != nil {
+			return fmt.Errorf("config: %s: %w", f.env, err)
+		}
+	}
+	return nil
+}
+
+// flagValue records a flag for Load to apply after the file and the
+// environment, while showing the built-in default in -help.
+type flagValue struct {
+	value value
+	def   string
+	flag  string
+	set   map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+func (f *flagValue) Set(v string) error {
+	// Fail on a malformed value now, with the flag package's usage message
+	if err := f.value.Set(v); err != nil {
+		return err
+	}
+	f.set[f.flag] = v
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	_, ok := f.value.(boolValue)
+	return ok
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) Set(s string) error { *v.p = s; return nil }
+func (v stringValue) String() string     { return *v.p }
+
+type intValue struct{ p *int }
+
+func (v intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	*v.p = n
+	return nil
+}
+func (v intValue) String() string { return strconv.Itoa(*v.p) }
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v.p = b
+	return nil
+}
+func (v boolValue) String() string { return strconv.FormatBool(*v.p) }
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q, want e.g. 5s or 1m30s", s)
+	}
+	*v.p = d
+	return nil
+}
+func (v durationValue) String() string { return v.p.String() }
+
+type levelValue struct{ p *slog.Level }
+
+func (v levelValue) Set(s string) error {
+	if err := v.p.UnmarshalText([]byte(s)); err != nil {
+		return errors.New("invalid level " + strconv.Quote(s) + ", want debug, info, warn or error")
+	}
+	return nil
+}
+func (v levelValue) String() string { return strings.ToLower(v.p.String()) }
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// load runs Load over a file holding doc, unless it is empty, the given
+// environment and flags. The required database settings come from the
+// environment unless env overrides them.
+func load(t *testing.T, doc string, env map[string]string, args ...string) (*Config, error) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	if doc != "" {
+		t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", doc))
+	}
+	t.Setenv("DATABASE_USER", "app")
+	t.Setenv("DATABASE_NAME", "shop")
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return Load(fs, args)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const file = "server:\n  port: 4000\n"
+	tests := []struct {
+		name string
+		doc  string
+		env  map[string]string
+		args []string
+		want int
+	}{
+		{"default", "", nil, nil, 3001},
+		{"file over default", file, nil, nil, 4000},
+		{"env over file", file, map[string]string{"PORT": "5000"}, nil, 5000},
+		{"flag over env", file, map[string]string{"PORT": "5000"}, []string{"-port", "6000"}, 6000},
+		{"flag over file", file, nil, []string{"-port=6000"}, 6000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := load(t, tt.doc, tt.env, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != tt.want {
+				t.Errorf("port = %d, want %d", cfg.Server.Port, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	secret := writeFile(t, "password", "s3cret\n")
+	tests := []struct {
+		name string
+		doc  string
+		env  map[string]string
+		want []string
+	}{
+		{"env and file", "", map[string]string{"DATABASE_PASSWORD": "x", "DATABASE_PASSWORD_FILE": secret},
+			[]string{"DATABASE_PASSWORD or DATABASE_PASSWORD_FILE, not both"}},
+		{"missing secret file", "", map[string]string{"DATABASE_PASSWORD_FILE": secret + ".missing"},
+			[]string{"DATABASE_PASSWORD_FILE"}},
+		{"unknown key", "server:\n  prot: 4000\n", nil, []string{`unknown setting "server.prot"`}},
+		{"malformed value", "server:\n  port: many\n", nil, []string{"server.port", `invalid integer "many"`}},
+		{"empty port", "", map[string]string{"PORT": ""}, []string{"PORT"}},
+		{"every invalid field", "server:\n  port: 0\njobs:\n  purge_after_days: 0\nlog:\n  format: xml\n",
+			map[string]string{"DATABASE_USER": ""},
+			[]string{"server.port", "jobs.purge_after_days", "log.format", "database.user"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := load(t, tt.doc, tt.env)
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadSecretFile(t *testing.T) {
+	cfg, err := load(t, "", map[string]string{"DATABASE_PASSWORD_FILE": writeFile(t, "password", "s3cret\r\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "s3cret" {
+		t.Errorf("password = %q, want the file without its line break", cfg.Database.Password)
+	}
+}
+
+func TestLoadEmptyCORSOrigin(t *testing.T) {
+	cfg, err := load(t, "server:\n  cors_origin: https://shop.example.com\n", map[string]string{"CORS_ORIGIN": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.CORSOrigin != "" {
+		t.Errorf("CORS_ORIGIN= left %q", cfg.Server.CORSOrigin)
+	}
+}
+
+func TestLoadEnvEmptyIsExplicit(t *testing.T) {
+	cfg := Default()
+	cfg.Server.CORSOrigin = "https://shop.example.com" // as if from the file
+	t.Setenv("CORS_ORIGIN", "")
+	if err := loadEnv(cfg.fields()); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.CORSOrigin != "" {
+		t.Errorf("CORS_ORIGIN= left %q", cfg.Server.CORSOrigin)
+	}
+	if cfg.Server.Port != Default().Server.Port {
+		t.Errorf("unset PORT changed the port to %d", cfg.Server.Port)
+	}
+
+	t.Setenv("PORT", "")
+	if err := loadEnv(cfg.fields()); err == nil {
+		t.Error("PORT= was accepted")
+	}
+}
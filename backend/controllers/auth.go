@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dto"
+)
+
+// RefreshToken rotates a refresh token and returns a new token pair
+func RefreshToken(tokens auth.Sessions, w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if !DecodeBody(w, r, &req) {
+		return
+	}
+
+	pair, err := tokens.Renew(r.Context(), req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefresh) || errors.Is(err, auth.ErrRefreshReused) {
+		apperr.Write(w, r, apperr.Unauthorized("Invalid refresh token"))
+		return
+	} else if err != nil {
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to refresh session: %w", err)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(dto.NewTokenResponse(pair))
+}
+
+// Logout revokes the session the given refresh token belongs to
+func Logout(tokens auth.Sessions, w http.ResponseWriter, r *http.Request) {
+	var req dto.RefreshRequest
+	if !DecodeBody(w, r, &req) {
+		return
+	}
+
+	if err := tokens.Logout(r.Context(), req.RefreshToken); err != nil {
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to log out: %w", err)))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,147 +1,119 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
-	"time"
 
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dto"
 	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/paging"
+	"github.com/mdarify1337/backend-go/backend/repository"
 )
 
 type RequestContext struct {
-	DB *sql.DB
-	W  http.ResponseWriter
-	R  *http.Request
+	Products repository.ProductRepository
+	W        http.ResponseWriter
+	R        *http.Request
 }
 
 func CreateProduct(data RequestContext) {
-	var product models.Product
-	if err := json.NewDecoder(data.R.Body).Decode(&product); err != nil {
-		http.Error(data.W, "Invalid request payload", http.StatusBadRequest)
+	principal, ok := auth.PrincipalFrom(data.R.Context())
+	if !ok {
+		apperr.Write(data.W, data.R, apperr.Unauthorized("Authentication required"))
 		return
 	}
 
-	// Timestamps
-	product.CreatedAt = time.Now().Format(time.RFC3339)
-	product.UpdatedAt = time.Now().Format(time.RFC3339)
-
-	// Insert into DB
-	query := `
-		INSERT INTO products (name, description, price, quantity, 
-		created_at, updated_at, user_id)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id;
-	`
-	err := data.DB.QueryRow(query,
-		product.Name,
-		product.Description,
-		product.Price,
-		product.Quantity,
-		product.CreatedAt,
-		product.UpdatedAt,
-		product.UserID,
-	).Scan(&product.ID)
+	var req dto.CreateProductRequest
+	if !DecodeBody(data.W, data.R, &req) {
+		return
+	}
+	product := req.ToModel()
 
-	if err != nil {
-		http.Error(data.W, fmt.Sprintf("DB insert error: %v", err),
-			http.StatusInternalServerError)
+	// Products belong to whoever creates them
+	product.UserID = principal.UserID
+
+	if err := data.Products.Create(data.R.Context(), &product); err != nil {
+		apperr.Write(data.W, data.R, err)
 		return
 	}
 
 	// Respond with created product
 	data.W.Header().Set("Content-Type", "application/json")
+	data.W.Header().Set("Location", fmt.Sprintf("/v1/products/%d", product.ID))
+	data.W.WriteHeader(http.StatusCreated)
 	json.NewEncoder(data.W).Encode(product)
-	fmt.Println("✅ Product saved:", product)
+	slog.InfoContext(data.R.Context(), "product created", "product_id", product.ID, "user_id", product.UserID)
 }
 
 func GetProducts(data RequestContext) {
-	query := `
-			SELECT id, 
-			name, 
-			description, 
-			price, 
-			quantity, 
-			created_at, 
-			updated_at, 
-			user_id FROM products;
-		`
-
-	rows, err := data.DB.Query(query)
-	if err != nil {
-		http.Error(data.W, fmt.Sprintf("DB query error: %v", err),
-			http.StatusInternalServerError)
+	listProducts(data, nil)
+}
+
+// GetUserProducts lists the products owned by userID
+func GetUserProducts(data RequestContext, userID int) {
+	listProducts(data, &userID)
+}
+
+var productFilters = []string{"min_price", "max_price", "in_stock", "user_id",
+	"created_after", "created_before", "include_deleted"}
+
+func listProducts(data RequestContext, ownerID *int) {
+	includeDeleted, ok := includeDeleted(data)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-
-	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		if err := rows.Scan(&product.ID, &product.Name,
-			&product.Description, &product.Price,
-			&product.Quantity, &product.CreatedAt,
-			&product.UpdatedAt, &product.UserID); err != nil {
-			http.Error(data.W, fmt.Sprintf("Row scan error: %v", err),
-				http.StatusInternalServerError)
-			return
-		}
-		products = append(products, product)
-	}
-
-	if err := rows.Err(); err != nil {
-		http.Error(data.W, fmt.Sprintf("Rows error: %v", err),
-			http.StatusInternalServerError)
+
+	params := queryParams{q: data.R.URL.Query()}
+	query := repository.ProductQuery{
+		MinPrice:       params.amount("min_price"),
+		MaxPrice:       params.amount("max_price"),
+		InStock:        params.bool("in_stock"),
+		UserID:         params.int("user_id"),
+		CreatedAfter:   params.time("created_after"),
+		CreatedBefore:  params.time("created_before"),
+		IncludeDeleted: includeDeleted,
+	}
+	// The owner in the path wins over the query filter, and cursors are
+	// bound to it
+	var scope []string
+	if ownerID != nil {
+		query.UserID = ownerID
+		scope = append(scope, "owner="+strconv.Itoa(*ownerID))
+	}
+	page, ok := params.page(data.W, data.R, sortNames(repository.ProductSorts), "-created_at", productFilters, scope...)
+	if !ok {
 		return
 	}
+	query.Page = page
 
-	data.W.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(data.W).Encode(products)
-}
-
-func GetProductByID(data RequestContext) {
-	// Extract ID from query parameter (?id=5)
-	idStr := data.R.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(data.W, "Missing product ID", http.StatusBadRequest)
+	products, err := data.Products.List(data.R.Context(), query)
+	if err != nil {
+		apperr.Write(data.W, data.R, err)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(data.W, "Invalid product ID", http.StatusBadRequest)
+	writePage(data.W, data.R, paging.NewPage(products, page, repository.ProductSorts[page.Sort],
+		func(p models.Product) int { return p.ID }, cursors))
+}
+
+func GetProductByID(data RequestContext, id int) {
+	includeDeleted, ok := includeDeleted(data)
+	if !ok {
 		return
 	}
 
-	// Query DB for a single product
-	query := `
-        SELECT id, 
-		name, 
-		description, 
-		price, 
-		quantity, 
-		created_at, 
-		updated_at, 
-		user_id
-        FROM products
-        WHERE id = $1;
-    `
-
-	var product models.Product
-	err = data.DB.QueryRow(query, id).Scan(
-		&product.ID, &product.Name, &product.Description,
-		&product.Price, &product.Quantity, &product.CreatedAt,
-		&product.UpdatedAt, &product.UserID,
-	)
-
-	if err == sql.ErrNoRows {
-		http.Error(data.W, "Product not found", http.StatusNotFound)
+	product, err := data.Products.Get(data.R.Context(), id, includeDeleted)
+	if errors.Is(err, repository.ErrNotFound) {
+		apperr.Write(data.W, data.R, apperr.NotFound("Product not found"))
 		return
 	} else if err != nil {
-		http.Error(data.W, fmt.Sprintf("DB query error: %v", err),
-			http.StatusInternalServerError)
+		apperr.Write(data.W, data.R, err)
 		return
 	}
 
@@ -150,56 +122,98 @@ func GetProductByID(data RequestContext) {
 	json.NewEncoder(data.W).Encode(product)
 }
 
-func UpdateProduct(data RequestContext) {
-	var product models.Product
-	if err := json.NewDecoder(data.R.Body).Decode(&product); err != nil {
-		http.Error(data.W, "Invalid request payload", http.StatusBadRequest)
+// UpdateProduct applies a partial update to the product with the given id
+func UpdateProduct(data RequestContext, id int) {
+	principal, ok := auth.PrincipalFrom(data.R.Context())
+	if !ok {
+		apperr.Write(data.W, data.R, apperr.Unauthorized("Authentication required"))
 		return
 	}
 
-	// Ensure ID is provided
-	if product.ID == 0 {
-		http.Error(data.W, "Missing product ID", http.StatusBadRequest)
+	var req dto.UpdateProductRequest
+	if !DecodeBody(data.W, data.R, &req) {
 		return
 	}
 
-	// Update timestamp
-	product.UpdatedAt = time.Now().Format(time.RFC3339)
-
-	// Update DB record
-	query := `
-		UPDATE products
-		SET name=$1, description=$2, price=$3, quantity=$4, updated_at=$5, user_id=$6
-		WHERE id=$7;
-	`
-	result, err := data.DB.Exec(query,
-		product.Name,
-		product.Description,
-		product.Price,
-		product.Quantity,
-		product.UpdatedAt,
-		product.UserID,
-		product.ID,
-	)
+	// Ownership never changes, so the caller either owns the product or may
+	// write anyone's
+	product, err := data.Products.Update(data.R.Context(), id, repository.ProductPatch{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       req.Price,
+		Currency:    req.Currency,
+		Quantity:    req.Quantity,
+	}, ownerOf(principal))
 	if err != nil {
-		http.Error(data.W, fmt.Sprintf("DB update error: %v", err),
-			http.StatusInternalServerError)
+		writeProductError(data, err)
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		http.Error(data.W, fmt.Sprintf("RowsAffected error: %v", err),
-			http.StatusInternalServerError)
+	// Respond with updated product
+	data.W.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(data.W).Encode(product)
+	slog.InfoContext(data.R.Context(), "product updated", "product_id", product.ID, "user_id", principal.UserID)
+}
+
+// DeleteProduct soft-deletes a product; it can be restored until purged
+func DeleteProduct(data RequestContext, id int) {
+	setProductDeleted(data, id, true)
+}
+
+// RestoreProduct brings back a soft-deleted product
+func RestoreProduct(data RequestContext, id int) {
+	setProductDeleted(data, id, false)
+}
+
+func setProductDeleted(data RequestContext, id int, deleted bool) {
+	principal, ok := auth.PrincipalFrom(data.R.Context())
+	if !ok {
+		apperr.Write(data.W, data.R, apperr.Unauthorized("Authentication required"))
 		return
 	}
-	if rowsAffected == 0 {
-		http.Error(data.W, "No product found with given ID", http.StatusNotFound)
+
+	product, err := data.Products.SetDeleted(data.R.Context(), id, deleted, ownerOf(principal))
+	if err != nil {
+		writeProductError(data, err)
 		return
 	}
 
-	// Respond with updated product
 	data.W.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(data.W).Encode(product)
-	fmt.Println("✅ Product updated:", product)
+	if deleted {
+		slog.InfoContext(data.R.Context(), "product soft-deleted", "product_id", id, "user_id", principal.UserID)
+	} else {
+		slog.InfoContext(data.R.Context(), "product restored", "product_id", id, "user_id", principal.UserID)
+	}
+}
+
+// includeDeleted reads ?include_deleted=true, which only callers allowed to
+// see deleted products may use. It writes the error response itself.
+func includeDeleted(data RequestContext) (bool, bool) {
+	if data.R.URL.Query().Get("include_deleted") != "true" {
+		return false, true
+	}
+	principal, ok := auth.PrincipalFrom(data.R.Context())
+	if !ok || !principal.Can(auth.PermProductsDeleted) {
+		apperr.Write(data.W, data.R, apperr.Forbidden("Not allowed to list deleted products"))
+		return false, false
+	}
+	return true, true
+}
+
+func ownerOf(p auth.Principal) repository.Owner {
+	return repository.Owner{UserID: p.UserID, Any: p.Can(auth.PermProductsWriteAny)}
+}
+
+// writeProductError explains why a guarded write failed: the product does
+// not exist in the expected state (404) or belongs to someone else (403).
+func writeProductError(data RequestContext, err error) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		apperr.Write(data.W, data.R, apperr.NotFound("No product found with given ID"))
+	case errors.Is(err, repository.ErrNotOwner):
+		apperr.Write(data.W, data.R, apperr.Forbidden("You do not own this product"))
+	default:
+		apperr.Write(data.W, data.R, err)
+	}
 }
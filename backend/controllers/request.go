@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/httpx"
+	"github.com/mdarify1337/backend-go/backend/money"
+	"github.com/mdarify1337/backend-go/backend/paging"
+	"github.com/mdarify1337/backend-go/backend/validate"
+)
+
+// DecodeBody reads the JSON body into dst and validates it. On failure it
+// writes the problem response itself and returns false.
+func DecodeBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	httpx.LimitBody(w, r)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apperr.Write(w, r, err)
+		} else {
+			apperr.Write(w, r, apperr.BadRequest("Invalid request payload"))
+		}
+		return false
+	}
+	if err := validate.Struct(dst); err != nil {
+		apperr.Write(w, r, err)
+		return false
+	}
+	return true
+}
+
+// queryParams parses optional query parameters, collecting every invalid
+// one so they can be reported together.
+type queryParams struct {
+	q    url.Values
+	errs []apperr.FieldError
+}
+
+func (p *queryParams) fail(name, code, detail string) {
+	p.errs = append(p.errs, apperr.FieldError{Parameter: name, Code: code, Detail: detail})
+}
+
+func (p *queryParams) int(name string) *int {
+	v := p.q.Get(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		p.fail(name, "type", "must be an integer")
+		return nil
+	}
+	return &n
+}
+
+func (p *queryParams) amount(name string) *money.Amount {
+	v := p.q.Get(name)
+	if v == "" {
+		return nil
+	}
+	a, err := money.Parse(v)
+	if err != nil {
+		p.fail(name, "type", "must be a decimal amount")
+		return nil
+	}
+	return &a
+}
+
+func (p *queryParams) bool(name string) *bool {
+	v := p.q.Get(name)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		p.fail(name, "type", "must be true or false")
+		return nil
+	}
+	return &b
+}
+
+func (p *queryParams) time(name string) *time.Time {
+	v := p.q.Get(name)
+	if v == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		p.fail(name, "format", "must be an RFC 3339 timestamp")
+		return nil
+	}
+	return &t
+}
+
+// page parses the paging parameters next to the filters. On any invalid
+// parameter it writes the problem response itself and returns false.
+func (p *queryParams) page(w http.ResponseWriter, r *http.Request,
+	sorts []string, defaultSort string, filters []string, scope ...string) (paging.Request, bool) {
+	req, err := paging.Parse(p.q, cursors, sorts, defaultSort, filters, scope...)
+	var invalid *apperr.Error
+	if errors.As(err, &invalid) {
+		p.errs = append(p.errs, invalid.Errors...)
+	}
+	if len(p.errs) > 0 {
+		apperr.Write(w, r, apperr.Invalid(p.errs))
+		return req, false
+	}
+	return req, true
+}
+
+// writePage sends a page of results with a Link header to the next one.
+func writePage[T any](w http.ResponseWriter, r *http.Request, page paging.Page[T]) {
+	paging.SetLink(w, r, page.NextCursor)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// cursors signs the pagination cursors handed out by list endpoints.
+var cursors = paging.EphemeralCodec()
+
+// SetCursorCodec replaces the codec of pagination cursors. Call it before
+// serving.
+func SetCursorCodec(c *paging.Codec) {
+	cursors = c
+}
+
+// sortNames lists the keys of a sort whitelist in a stable order.
+func sortNames[T any](fields map[string]paging.Field[T]) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dto"
+)
+
+// GetUserRoles writes the roles held by userID
+func GetUserRoles(roles auth.RoleRepository, w http.ResponseWriter, r *http.Request, userID int) {
+	held, err := roles.UserRoles(r.Context(), userID)
+	if err != nil {
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to load roles: %w", err)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.UserRolesResponse{UserID: userID, Roles: held})
+}
+
+// GrantRole gives role to userID
+func GrantRole(roles auth.RoleRepository, w http.ResponseWriter, r *http.Request, userID int, role string) {
+	err := roles.Grant(r.Context(), userID, role)
+	if errors.Is(err, auth.ErrUnknownRole) {
+		apperr.Write(w, r, apperr.BadRequest("Unknown role"))
+		return
+	} else if errors.Is(err, auth.ErrUnknownUser) {
+		apperr.Write(w, r, apperr.NotFound("No user found with given ID"))
+		return
+	} else if err != nil {
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to grant role: %w", err)))
+		return
+	}
+	slog.InfoContext(r.Context(), "role granted", "role", role, "user_id", userID)
+	GetUserRoles(roles, w, r, userID)
+}
+
+// RevokeRole takes role away from userID
+func RevokeRole(roles auth.RoleRepository, w http.ResponseWriter, r *http.Request, userID int, role string) {
+	held, err := roles.Revoke(r.Context(), userID, role)
+	if errors.Is(err, auth.ErrUnknownRole) {
+		apperr.Write(w, r, apperr.BadRequest("Unknown role"))
+		return
+	} else if err != nil {
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to revoke role: %w", err)))
+		return
+	}
+	if !held {
+		apperr.Write(w, r, apperr.NotFound("User does not hold this role"))
+		return
+	}
+	slog.InfoContext(r.Context(), "role revoked", "role", role, "user_id", userID)
+	GetUserRoles(roles, w, r, userID)
+}
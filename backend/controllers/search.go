@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/dto"
+	"github.com/mdarify1337/backend-go/backend/paging"
+	"github.com/mdarify1337/backend-go/backend/repository"
+)
+
+const maxSearchQuery = 200
+
+// SearchProducts ranks products by how well their name and description
+// match ?q=. When nothing matches it falls back to names that look similar,
+// so "iphnoe" still finds "iPhone".
+func SearchProducts(data RequestContext) {
+	params := queryParams{q: data.R.URL.Query()}
+	q := strings.TrimSpace(params.q.Get("q"))
+	switch {
+	case q == "":
+		params.fail("q", "required", "is required")
+	case utf8.RuneCountInString(q) > maxSearchQuery:
+		params.fail("q", "max", fmt.Sprintf("must be at most %d characters", maxSearchQuery))
+	}
+	limit := paging.DefaultLimit
+	if n := params.int("limit"); n != nil {
+		if *n < 1 || *n > paging.MaxLimit {
+			params.fail("limit", "range", fmt.Sprintf("must be between 1 and %d", paging.MaxLimit))
+		} else {
+			limit = *n
+		}
+	}
+	if len(params.errs) > 0 {
+		apperr.Write(data.W, data.R, apperr.Invalid(params.errs))
+		return
+	}
+
+	result, err := data.Products.Search(data.R.Context(), q, limit)
+	if err != nil {
+		apperr.Write(data.W, data.R, err)
+		return
+	}
+
+	response := dto.ProductSearchResponse{Query: q, Match: "fulltext", Data: []dto.ProductSearchResult{}}
+	if result.Fuzzy {
+		response.Match = "fuzzy"
+	}
+	for _, hit := range result.Hits {
+		response.Data = append(response.Data, dto.ProductSearchResult{
+			Product: hit.Product,
+			Rank:    hit.Rank,
+			Highlights: dto.ProductHighlight{
+				Name:        highlight(hit.NameHighlight),
+				Description: highlight(hit.DescriptionHighlight),
+			},
+		})
+	}
+
+	data.W.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(data.W).Encode(response)
+}
+
+// highlight escapes a snippet and turns the match markers into <mark> tags,
+// so product text can never smuggle markup into a highlight.
+func highlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	return strings.NewReplacer(repository.HighlightStart, "<mark>",
+		repository.HighlightStop, "</mark>").Replace(escaped)
+}
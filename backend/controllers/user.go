@@ -1,211 +1,156 @@
 package controllers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
-	"strconv"
-	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dto"
+	"github.com/mdarify1337/backend-go/backend/metrics"
 	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/paging"
+	"github.com/mdarify1337/backend-go/backend/password"
+	"github.com/mdarify1337/backend-go/backend/repository"
 )
 
-// CreateUser inserts a new user into the DB
-func CreateUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	// Timestamps
-	user.CreatedAt = time.Now().Format(time.RFC3339)
-	user.UpdatedAt = time.Now().Format(time.RFC3339)
-
-	// Insert into DB
-	query := `
-		INSERT INTO users (username, email, password, first_name, 
-		last_name, created_at, updated_at, picture)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id;
-	`
-	err := db.QueryRow(query,
-		user.Username,
-		user.Email,
-		user.Password,
-		user.FirstName,
-		user.LastName,
-		user.CreatedAt,
-		user.UpdatedAt,
-		user.Picture,
-	).Scan(&user.ID)
+var (
+	usersCreated = metrics.Factory.NewCounter(prometheus.CounterOpts{
+		Name: "users_created_total",
+		Help: "User accounts created.",
+	})
+	// reason is unknown_user or bad_password
+	signInFailures = metrics.Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "sign_in_failures_total",
+		Help: "Sign-in attempts rejected, by reason.",
+	}, []string{"reason"})
+)
 
-	if err != nil {
-		http.Error(w, fmt.Sprintf("DB insert error: %v", err), http.StatusInternalServerError)
+// CreateUser inserts a new user into the DB
+func CreateUser(users repository.UserRepository, w http.ResponseWriter, r *http.Request) {
+	var req dto.CreateUserRequest
+	if !DecodeBody(w, r, &req) {
 		return
 	}
+	user := req.ToModel()
 
-	// Respond with created user
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
-	fmt.Println("✅ User saved:", user)
-}
-
-func GetUsers(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	query := `SELECT id, 
-		username, email, 
-		password, first_name, 
-		last_name, created_at, 
-		updated_at, picture 
-		FROM users;
-	`
-	rows, err := db.Query(query)
+	hash, err := password.Hash(user.Password)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("DB query error: %v", err),
-			http.StatusInternalServerError)
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to hash password: %w", err)))
 		return
 	}
-	defer rows.Close()
+	user.Password = hash
 
-	var users []models.User
-	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Username, &user.Email, &user.Password,
-			&user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt,
-			&user.Picture); err != nil {
-			http.Error(w, fmt.Sprintf("Row scan error: %v", err),
-				http.StatusInternalServerError)
-			return
-		}
-		users = append(users, user)
-	}
-
-	if err := rows.Err(); err != nil {
-		http.Error(w, fmt.Sprintf("Rows error: %v", err),
-			http.StatusInternalServerError)
+	// Every account starts out as a customer
+	if err := users.Create(r.Context(), &user, auth.RoleCustomer); err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
+	// Respond with created user
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	w.Header().Set("Location", fmt.Sprintf("/v1/users/%d", user.ID))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+	usersCreated.Inc()
+	slog.InfoContext(r.Context(), "user created", "user_id", user.ID)
 }
 
-func UpdateUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
-		return
-	}
-
-	// Update timestamp
-	user.UpdatedAt = time.Now().Format(time.RFC3339)
-
-	// Update DB record
-	query := `
-		UPDATE users 
-		SET username=$1, email=$2, password=$3, 
-		    first_name=$4, last_name=$5, 
-		    updated_at=$6, picture=$7
-		WHERE id=$8;
-	`
-	result, err := db.Exec(query,
-		user.Username,
-		user.Email,
-		user.Password,
-		user.FirstName,
-		user.LastName,
-		user.UpdatedAt,
-		user.Picture,
-		user.ID,
-	)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("DB update error: %v", err),
-			http.StatusInternalServerError)
+var userFilters = []string{"created_after", "created_before"}
+
+// GetUsers lists users a page at a time
+func GetUsers(users repository.UserRepository, w http.ResponseWriter, r *http.Request) {
+	params := queryParams{q: r.URL.Query()}
+	query := repository.UserQuery{
+		CreatedAfter:  params.time("created_after"),
+		CreatedBefore: params.time("created_before"),
+	}
+	page, ok := params.page(w, r, sortNames(repository.UserSorts), "-created_at", userFilters)
+	if !ok {
 		return
 	}
+	query.Page = page
 
-	rowsAffected, err := result.RowsAffected()
+	rows, err := users.List(r.Context(), query)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("RowsAffected error: %v", err),
-			http.StatusInternalServerError)
-		return
-	}
-	if rowsAffected == 0 {
-		http.Error(w, "No user found with given ID", http.StatusNotFound)
+		apperr.Write(w, r, err)
 		return
 	}
 
-	// Respond with updated user
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
-	fmt.Println("✅ User updated:", user)
+	result := paging.NewPage(rows, page, repository.UserSorts[page.Sort],
+		func(u models.User) int { return u.ID }, cursors)
+	writePage(w, r, paging.Page[dto.UserResponse]{
+		Data:       dto.NewUserResponses(result.Data),
+		NextCursor: result.NextCursor,
+	})
 }
 
-func GetUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	// Extract ID from query param
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "Missing user ID", http.StatusBadRequest)
+// UpdateUser applies a partial update to the user with the given id
+func UpdateUser(users repository.UserRepository, w http.ResponseWriter, r *http.Request, id int) {
+	var req dto.UpdateUserRequest
+	if !DecodeBody(w, r, &req) {
 		return
 	}
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
-		return
-	}
-
-	var user models.User
-	query := `SELECT id, username, email, password, first_name, last_name, created_at, updated_at, picture 
-	          FROM users WHERE id=$1;`
 
-	err = db.QueryRow(query, id).Scan(&user.ID, &user.Username, &user.Email, &user.Password,
-		&user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt, &user.Picture)
+	// An empty password keeps the stored hash
+	var hash *string
+	if req.Password != nil && *req.Password != "" {
+		h, err := password.Hash(*req.Password)
+		if err != nil {
+			apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to hash password: %w", err)))
+			return
+		}
+		hash = &h
+	}
 
-	if err == sql.ErrNoRows {
-		http.Error(w, "No user found with given ID", http.StatusNotFound)
+	user, err := users.Update(r.Context(), id, repository.UserPatch{
+		Username:     req.Username,
+		Email:        req.Email,
+		PasswordHash: hash,
+		FirstName:    req.FirstName,
+		LastName:     req.LastName,
+		Picture:      req.Picture,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		apperr.Write(w, r, apperr.NotFound("No user found with given ID"))
 		return
 	} else if err != nil {
-		http.Error(w, fmt.Sprintf("DB query error: %v", err), http.StatusInternalServerError)
+		apperr.Write(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
-	fmt.Println("✅ User fetched:", user)
+	// Respond with updated user
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+	slog.InfoContext(r.Context(), "user updated", "user_id", user.ID)
 }
 
-func DeleteUser(db *sql.DB, w http.ResponseWriter, r *http.Request, id int) {
-	log.Printf("[Controller] DeleteUser called with id=%d\n", id)
-
-	// Prepare the delete statement
-	stmt, err := db.Prepare("DELETE FROM users WHERE id = $1")
-	if err != nil {
-		http.Error(w, "Failed to prepare delete statement", http.StatusInternalServerError)
-		log.Printf("Error preparing delete statement: %v", err)
+func GetUser(users repository.UserRepository, w http.ResponseWriter, r *http.Request, id int) {
+	user, err := users.Get(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		apperr.Write(w, r, apperr.NotFound("No user found with given ID"))
 		return
-	}
-	defer stmt.Close()
-
-	// Execute the query
-	result, err := stmt.Exec(id)
-	if err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
-		log.Printf("Error executing delete: %v", err)
+	} else if err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
-	// Check if a row was affected
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		http.Error(w, "Failed to retrieve affected rows", http.StatusInternalServerError)
-		log.Printf("Error checking affected rows: %v", err)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dto.NewUserResponse(user))
+}
 
-	if rowsAffected == 0 {
-		http.Error(w, "User not found", http.StatusNotFound)
-		log.Printf("No user found with id=%d", id)
+func DeleteUser(users repository.UserRepository, w http.ResponseWriter, r *http.Request, id int) {
+	err := users.Delete(r.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		apperr.Write(w, r, apperr.NotFound("User not found"))
+		return
+	} else if err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
@@ -213,35 +158,63 @@ func DeleteUser(db *sql.DB, w http.ResponseWriter, r *http.Request, id int) {
 	response := map[string]string{"message": "User deleted successfully"}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
-
-	log.Printf("[Controller] User with id=%d deleted successfully\n", id)
+	slog.InfoContext(r.Context(), "user deleted", "user_id", id)
 }
 
-func SignInUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	var creds struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+// SignInUser checks credentials and starts a session
+func SignInUser(users repository.UserRepository, tokens auth.Sessions, w http.ResponseWriter, r *http.Request) {
+	var creds dto.SignInRequest
+	if !DecodeBody(w, r, &creds) {
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		http.Error(w, "Invalid request payload", http.StatusBadRequest)
+
+	user, err := users.GetByEmail(r.Context(), creds.Username)
+	if errors.Is(err, repository.ErrNotFound) {
+		// Burn the same time as a real check so unknown emails are not distinguishable
+		password.Verify(creds.Password, dummyHash)
+		signInFailures.WithLabelValues("unknown_user").Inc()
+		apperr.Write(w, r, apperr.Unauthorized("Invalid email or password"))
+		return
+	} else if err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
-	var user models.User
-	query := `SELECT id, username, email, password, first_name, last_name, created_at, updated_at, picture 
-	          FROM users WHERE email=$1 AND password=$2;`
+	ok, needsRehash, err := password.Verify(creds.Password, user.Password)
+	if err != nil {
+		slog.WarnContext(r.Context(), "unverifiable password hash", "user_id", user.ID, "err", err)
+	}
+	if !ok {
+		signInFailures.WithLabelValues("bad_password").Inc()
+		apperr.Write(w, r, apperr.Unauthorized("Invalid email or password"))
+		return
+	}
 
-	err := db.QueryRow(query, creds.Username, creds.Password).Scan(&user.ID, &user.Username, &user.Email,
-		&user.Password, &user.FirstName, &user.LastName, &user.CreatedAt, &user.UpdatedAt, &user.Picture)
+	// Transparently upgrade legacy or weaker hashes
+	if needsRehash {
+		if hash, err := password.Hash(creds.Password); err == nil {
+			if err := users.SetPassword(r.Context(), user.ID, hash); err != nil {
+				slog.ErrorContext(r.Context(), "failed to rehash password", "user_id", user.ID, "err", err)
+			} else {
+				user.Password = hash
+			}
+		}
+	}
 
-	if err == sql.ErrNoRows {
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
-		return
-	} else if err != nil {
-		http.Error(w, fmt.Sprintf("DB query error: %v", err), http.StatusInternalServerError)
+	pair, err := tokens.Login(r.Context(), user.ID)
+	if err != nil {
+		apperr.Write(w, r, apperr.Internal(fmt.Errorf("failed to start session: %w", err)))
 		return
 	}
 
-	json.NewEncoder(w).Encode(user)
-	fmt.Println("✅ User signed in:", user)
+	response := dto.NewTokenResponse(pair)
+	profile := dto.NewUserResponse(user)
+	response.User = &profile
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+	slog.InfoContext(r.Context(), "user signed in", "user_id", user.ID)
 }
+
+// dummyHash is verified against when no user matches, to keep sign-in timing uniform.
+var dummyHash, _ = password.Hash("dummy-password")
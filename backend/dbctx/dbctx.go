@@ -0,0 +1,60 @@
+// Package dbctx bounds database operations in time, traces each one as a
+// child span and records why one was cut short, so the API can tell a slow
+// query from a client that left.
+package dbctx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Timeouts caps single database operations. A zero value leaves the
+// caller's context as the only limit.
+type Timeouts struct {
+	Read  time.Duration
+	Write time.Duration
+}
+
+var tracer = otel.Tracer("github.com/mdarify1337/backend-go/backend/dbctx")
+
+// ForRead derives the context of the read named op, such as
+// "products.list". Defer the returned func with the address of the
+// operation's error: it ends the span, releases the context and, when the
+// deadline or the caller's cancellation ended the operation, wraps the
+// error with context.DeadlineExceeded or context.Canceled.
+func (t Timeouts) ForRead(ctx context.Context, op string) (context.Context, func(*error)) {
+	return bound(ctx, t.Read, op)
+}
+
+// ForWrite is ForRead for statements that change data.
+func (t Timeouts) ForWrite(ctx context.Context, op string) (context.Context, func(*error)) {
+	return bound(ctx, t.Write, op)
+}
+
+func bound(ctx context.Context, d time.Duration, op string) (context.Context, func(*error)) {
+	ctx, span := tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(op)))
+	cancel := context.CancelFunc(func() {})
+	if d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+	}
+	return ctx, func(err *error) {
+		// The driver reports a cancelled statement in its own words
+		if *err != nil {
+			if cause := ctx.Err(); cause != nil && !errors.Is(*err, cause) {
+				*err = fmt.Errorf("%w: %w", cause, *err)
+			}
+			span.RecordError(*err)
+			span.SetStatus(codes.Error, (*err).Error())
+		}
+		cancel()
+		span.End()
+	}
+}
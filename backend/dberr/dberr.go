@@ -0,0 +1,68 @@
+// Package dberr turns Postgres constraint violations into API errors that
+// name the offending field, instead of leaking raw driver messages.
+package dberr
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// constraintFields maps constraint names to the JSON field they guard.
+var constraintFields = map[string]string{
+	"users_email_lower_key":    "email",
+	"users_username_lower_key": "username",
+	"products_user_id_fkey":    "user_id",
+}
+
+// Translate returns an *apperr.Error for unique and foreign key violations
+// and err unchanged otherwise.
+func Translate(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	field := constraintFields[pqErr.Constraint]
+
+	switch pqErr.Code {
+	case uniqueViolation:
+		return Taken(field, err)
+	case foreignKeyViolation:
+		return MissingReference(field, err)
+	}
+	return err
+}
+
+// Taken is the error for a value that must be unique, naming field when it
+// is known. Stores other than Postgres use it to report the same conflict.
+func Taken(field string, err error) *apperr.Error {
+	if field == "" {
+		return &apperr.Error{Kind: apperr.KindConflict,
+			Detail: "The resource already exists", Err: err}
+	}
+	e := apperr.Conflict("The " + field + " is already taken")
+	e.Errors = []apperr.FieldError{{Pointer: "/" + field, Code: "unique",
+		Detail: "is already taken"}}
+	e.Err = err
+	return e
+}
+
+// MissingReference is the error for a field pointing at a record that does
+// not exist.
+func MissingReference(field string, err error) *apperr.Error {
+	if field == "" {
+		return &apperr.Error{Kind: apperr.KindConflict,
+			Detail: "The resource is referenced by or refers to a missing record", Err: err}
+	}
+	e := apperr.Invalid([]apperr.FieldError{{Pointer: "/" + field, Code: "exists",
+		Detail: "refers to a record that does not exist"}})
+	e.Err = err
+	return e
+}
@@ -0,0 +1,31 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+)
+
+// TokenResponse is returned by sign-in and refresh.
+type TokenResponse struct {
+	AccessToken  string        `json:"access_token"`
+	TokenType    string        `json:"token_type"`
+	ExpiresIn    int           `json:"expires_in"`
+	RefreshToken string        `json:"refresh_token"`
+	User         *UserResponse `json:"user,omitempty"`
+}
+
+// NewTokenResponse maps a token pair to its wire form.
+func NewTokenResponse(p auth.Pair) TokenResponse {
+	return TokenResponse{
+		AccessToken:  p.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(time.Until(p.ExpiresAt).Seconds()),
+		RefreshToken: p.RefreshToken,
+	}
+}
+
+// RefreshRequest is the body of refresh and logout calls.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
@@ -0,0 +1,67 @@
+package dto
+
+import (
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/money"
+)
+
+// CreateProductRequest is the body of a product creation. The owner is the
+// signed-in user and cannot be chosen by the client. Price is a decimal
+// string such as "19.99" and is rounded to cents; it is required, so a
+// forgotten price is not stored as 0.00. Currency defaults to USD.
+type CreateProductRequest struct {
+	Name        string        `json:"name" validate:"required,max=100"`
+	Description string        `json:"description" validate:"max=5000"`
+	Price       *money.Amount `json:"price" validate:"required,min=0,max=99999999.99"`
+	Currency    string        `json:"currency" validate:"omitempty,currency"`
+	Quantity    int           `json:"quantity" validate:"min=0"`
+}
+
+// ToModel maps the request onto a new product row. Call it on a validated
+// request only.
+func (req CreateProductRequest) ToModel() models.Product {
+	currency := req.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	return models.Product{
+		Name:        req.Name,
+		Description: req.Description,
+		Price:       *req.Price,
+		Currency:    currency,
+		Quantity:    req.Quantity,
+	}
+}
+
+// UpdateProductRequest changes a product. Fields left out of the body stay
+// as they are. ID is only read by the legacy /UpdateProduct route.
+type UpdateProductRequest struct {
+	ID          int           `json:"id"`
+	Name        *string       `json:"name" validate:"min=1,max=100"`
+	Description *string       `json:"description" validate:"max=5000"`
+	Price       *money.Amount `json:"price" validate:"min=0,max=99999999.99"`
+	Currency    *string       `json:"currency" validate:"currency"`
+	Quantity    *int          `json:"quantity" validate:"min=0"`
+}
+
+// ProductSearchResult is one search hit. Highlights are HTML-escaped, with
+// the matched terms wrapped in <mark> tags.
+type ProductSearchResult struct {
+	models.Product
+	Rank       float64          `json:"rank"`
+	Highlights ProductHighlight `json:"highlights"`
+}
+
+// ProductHighlight holds the highlighted snippets of a search hit.
+type ProductHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ProductSearchResponse lists search hits by relevance. Match is "fulltext",
+// or "fuzzy" when no word matched and similar names were returned instead.
+type ProductSearchResponse struct {
+	Query string                `json:"query"`
+	Match string                `json:"match"`
+	Data  []ProductSearchResult `json:"data"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+// RoleRequest is the body of grant and revoke calls.
+type RoleRequest struct {
+	UserID int    `json:"user_id" validate:"min=1"`
+	Role   string `json:"role" validate:"required"`
+}
+
+// UserRolesResponse lists the roles a user holds.
+type UserRolesResponse struct {
+	UserID int      `json:"user_id"`
+	Roles  []string `json:"roles"`
+}
@@ -0,0 +1,89 @@
+// Package dto holds the shapes the API reads from and writes to clients,
+// kept apart from the database models so secrets cannot leak by accident.
+package dto
+
+import (
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/models"
+)
+
+// CreateUserRequest is the body of a sign-up.
+type CreateUserRequest struct {
+	Username  string `json:"username" validate:"required,min=3,max=50"`
+	Email     string `json:"email" validate:"required,email,max=100"`
+	Password  string `json:"password" validate:"required,password"`
+	FirstName string `json:"first_name" validate:"max=50"`
+	LastName  string `json:"last_name" validate:"max=50"`
+	Picture   string `json:"picture" validate:"max=2048"`
+}
+
+// ToModel maps the request onto a new user row. The password is copied as
+// given; callers hash it before storing.
+func (req CreateUserRequest) ToModel() models.User {
+	return models.User{
+		Username:  req.Username,
+		Email:     req.Email,
+		Password:  req.Password,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Picture:   req.Picture,
+	}
+}
+
+// UpdateUserRequest changes a user's profile. Fields left out of the body
+// stay as they are. ID is only read by the legacy /UpdateUser route; the
+// resource route takes it from the path.
+type UpdateUserRequest struct {
+	ID        int     `json:"id"`
+	Username  *string `json:"username" validate:"min=3,max=50"`
+	Email     *string `json:"email" validate:"email,max=100"`
+	Password  *string `json:"password" validate:"omitempty,password"`
+	FirstName *string `json:"first_name" validate:"max=50"`
+	LastName  *string `json:"last_name" validate:"max=50"`
+	Picture   *string `json:"picture" validate:"max=2048"`
+}
+
+// SignInRequest carries login credentials. Username holds the email address.
+type SignInRequest struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// UserResponse is the only shape in which a user leaves the API. It has no
+// password field, so no handler can serialise one.
+type UserResponse struct {
+	ID        int              `json:"id"`
+	Username  string           `json:"username"`
+	Email     string           `json:"email"`
+	FirstName string           `json:"first_name"`
+	LastName  string           `json:"last_name"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	Picture   string           `json:"picture"`
+	Products  []models.Product `json:"products,omitempty"`
+}
+
+// NewUserResponse maps a user row to its public representation.
+func NewUserResponse(u models.User) UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		Picture:   u.Picture,
+		Products:  u.Products,
+	}
+}
+
+// NewUserResponses maps a list of user rows, never returning nil.
+func NewUserResponses(users []models.User) []UserResponse {
+	out := make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		out = append(out, NewUserResponse(u))
+	}
+	return out
+}
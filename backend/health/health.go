@@ -0,0 +1,136 @@
+// Package health reports whether the process is alive and whether it should
+// receive traffic.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/migrations"
+)
+
+// Check probes one dependency. Run returns nil when it is usable.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Database checks that a pooled connection answers.
+func Database(db *sql.DB) Check {
+	return Check{Name: "database", Run: db.PingContext}
+}
+
+// Migrations checks that the schema is at the version this binary expects.
+func Migrations(m *migrations.Migrator) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		version, dirty, err := m.Current(ctx)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			return fmt.Errorf("version %d is dirty", version)
+		}
+		if want := m.Latest(); version != want {
+			return fmt.Errorf("at version %d, want %d", version, want)
+		}
+		return nil
+	}}
+}
+
+// Live answers 200 for as long as the process can serve HTTP at all.
+func Live(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, Report{Status: "ok"})
+}
+
+// Readiness runs its checks on every probe and fails once the server starts
+// shutting down, so load balancers stop routing to it while in-flight
+// requests drain.
+type Readiness struct {
+	timeout  time.Duration
+	checks   []Check
+	draining atomic.Bool
+}
+
+// NewReadiness returns a probe running checks concurrently, each given at
+// most timeout.
+func NewReadiness(timeout time.Duration, checks ...Check) *Readiness {
+	return &Readiness{timeout: timeout, checks: checks}
+}
+
+// Drain makes every later readiness check fail.
+func (r *Readiness) Drain() { r.draining.Store(true) }
+
+// Draining reports whether Drain was called.
+func (r *Readiness) Draining() bool { return r.draining.Load() }
+
+// Report is the body of both probes.
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is the outcome of one Check.
+type CheckResult struct {
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ServeHTTP answers 200 when every check passes and 503 otherwise or once
+// draining.
+func (r *Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.Draining() {
+		writeJSON(w, http.StatusServiceUnavailable, Report{Status: "draining"})
+		return
+	}
+
+	report := Report{Status: "ok", Checks: map[string]CheckResult{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range r.checks {
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			result := r.run(req.Context(), c)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.Name] = result
+			if result.Status != "ok" {
+				report.Status = "unavailable"
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if report.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
+
+func (r *Readiness) run(ctx context.Context, c Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Run(ctx)
+	result := CheckResult{Status: "ok", LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status, result.Error = "fail", err.Error()
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
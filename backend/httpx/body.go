@@ -0,0 +1,13 @@
+package httpx
+
+import "net/http"
+
+// MaxBodyBytes caps every request body read by the API. Reading past it
+// fails with an *http.MaxBytesError, which apperr.Write answers with 413.
+const MaxBodyBytes = 1 << 20
+
+// LimitBody caps r.Body at MaxBodyBytes. It must run before the body is
+// read; capping it again is harmless.
+func LimitBody(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+}
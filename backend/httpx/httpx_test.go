@@ -0,0 +1,48 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecordShared(t *testing.T) {
+	var inner *Recorder
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		inner = Record(w)
+		http.Error(inner, "nope", http.StatusTeapot)
+	}
+	outer := Record(httptest.NewRecorder())
+	handler(outer, httptest.NewRequest("GET", "/", nil))
+
+	if inner != outer {
+		t.Fatal("nested Record wrapped the recorder again")
+	}
+	if outer.Status() != http.StatusTeapot || outer.Bytes() != int64(len("nope\n")) {
+		t.Errorf("status = %d, bytes = %d", outer.Status(), outer.Bytes())
+	}
+}
+
+func TestResolve(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /items/{id}", func(http.ResponseWriter, *http.Request) {})
+
+	tests := []struct{ method, path, pattern string }{
+		{"GET", "/items/7", "GET /items/{id}"},
+		{"POST", "/items/7", ""},
+		{"GET", "/nowhere", ""},
+	}
+	for _, tt := range tests {
+		var got Match
+		Resolve(mux, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = Lookup(nil, r) // must not resolve again
+			if Route(r.Context()) != got.Pattern {
+				t.Errorf("Route = %q, Lookup = %q", Route(r.Context()), got.Pattern)
+			}
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+		if got.Pattern != tt.pattern || got.Handler == nil {
+			t.Errorf("%s %s matched %q, want %q", tt.method, tt.path, got.Pattern, tt.pattern)
+		}
+	}
+}
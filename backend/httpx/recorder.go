@@ -0,0 +1,49 @@
+// Package httpx holds the HTTP plumbing the middleware share: one recorder
+// of the response status and size, and the route a request matched, looked
+// up once for all of them.
+package httpx
+
+import "net/http"
+
+// Recorder remembers the status and size of a response.
+type Recorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// Record returns a Recorder writing to w. When w already is one, as it is
+// for every middleware inside the first that records, it is returned as is
+// so a request has a single recorder.
+func Record(w http.ResponseWriter) *Recorder {
+	if rec, ok := w.(*Recorder); ok {
+		return rec
+	}
+	return &Recorder{ResponseWriter: w}
+}
+
+// Status is the status sent so far: 200 once the body was written without
+// an explicit status, 0 when nothing was written.
+func (r *Recorder) Status() int { return r.status }
+
+// Bytes is the size of the body written so far.
+func (r *Recorder) Bytes() int64 { return r.bytes }
+
+func (r *Recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *Recorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,44 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+)
+
+// Match is what a ServeMux routes a request to. Pattern is "" when no
+// route matched and Handler answers with the mux's 404 or 405.
+type Match struct {
+	Handler http.Handler
+	Pattern string
+}
+
+type matchKey struct{}
+
+// Resolve looks up the route mux gives each request and stores it in the
+// request context, so the middleware inside share one lookup instead of
+// each asking the mux. Dispatch still goes through mux.ServeHTTP, which
+// matches the request again: only it fills in the wildcards read by
+// Request.PathValue.
+func Resolve(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		ctx := context.WithValue(r.Context(), matchKey{}, Match{Handler: h, Pattern: pattern})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Lookup returns the match Resolve stored for r, or resolves it against
+// mux when r did not pass through Resolve.
+func Lookup(mux *http.ServeMux, r *http.Request) Match {
+	if m, ok := r.Context().Value(matchKey{}).(Match); ok {
+		return m
+	}
+	h, pattern := mux.Handler(r)
+	return Match{Handler: h, Pattern: pattern}
+}
+
+// Route returns the pattern Resolve stored in ctx, or "".
+func Route(ctx context.Context) string {
+	m, _ := ctx.Value(matchKey{}).(Match)
+	return m.Pattern
+}
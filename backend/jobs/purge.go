@@ -0,0 +1,52 @@
+// Package jobs holds background work that runs alongside the HTTP server.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+)
+
+// PurgeDeletedProducts hard-deletes products soft-deleted more than
+// retention ago and returns how many rows were removed. The delete is traced
+// as products.purge and bounded by the write timeout; a purge cut short
+// removes nothing and is retried on the next run.
+func PurgeDeletedProducts(ctx context.Context, db *sql.DB, timeouts dbctx.Timeouts,
+	retention time.Duration) (n int64, err error) {
+	ctx, done := timeouts.ForWrite(ctx, "products.purge")
+	defer done(&err)
+
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM products
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1;
+	`, time.Now().Add(-retention))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge products: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// RunProductPurge calls PurgeDeletedProducts every interval until ctx is
+// cancelled. Several replicas running it at once is harmless.
+func RunProductPurge(ctx context.Context, db *sql.DB, timeouts dbctx.Timeouts, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := PurgeDeletedProducts(ctx, db, timeouts, retention)
+		if err != nil {
+			slog.ErrorContext(ctx, "product purge failed", "err", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "purged soft-deleted products", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/httpx"
+)
+
+type entryKey struct{}
+
+// entry collects what inner handlers know about a request, such as the
+// matched route or the caller, for its access log line.
+type entry struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+// Annotate adds attrs to the access log line of the request ctx belongs to.
+// It does nothing outside AccessLog.
+func Annotate(ctx context.Context, attrs ...slog.Attr) {
+	e, ok := ctx.Value(entryKey{}).(*entry)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.attrs = append(e.attrs, attrs...)
+}
+
+// AccessLog writes one line per request once the response is complete:
+// method, path, status, bytes written and latency, plus whatever inner
+// handlers passed to Annotate. Server errors are logged at error level.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		e := &entry{}
+		rec := httpx.Record(w)
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), entryKey{}, e)))
+
+		status := rec.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", status),
+			slog.Int64("bytes", rec.Bytes()),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		e.mu.Lock()
+		attrs = append(attrs, e.attrs...)
+		e.mu.Unlock()
+
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		slog.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
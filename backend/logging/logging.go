@@ -0,0 +1,85 @@
+// Package logging configures the process-wide slog logger: text or JSON
+// output, a minimum level, the request and trace IDs on every record logged
+// with a request context, and redaction of attributes that may hold secrets.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mdarify1337/backend-go/backend/requestid"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config selects the output format and the minimum level.
+type Config struct {
+	Format string
+	Level  slog.Level
+}
+
+// New returns a logger writing to w as described by cfg.
+func New(w io.Writer, cfg Config) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: cfg.Level, ReplaceAttr: redact}
+	var h slog.Handler
+	if cfg.Format == FormatJSON {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// Setup makes a logger built from cfg the default, which also routes the
+// standard log package through it.
+func Setup(cfg Config) {
+	slog.SetDefault(New(os.Stderr, cfg))
+}
+
+// Redacted replaces the value of every attribute whose key names a secret.
+const Redacted = "[REDACTED]"
+
+var sensitive = []string{"password", "secret", "token", "authorization", "cookie", "dsn", "hash"}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, s := range sensitive {
+		if strings.Contains(key, s) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
+
+// contextHandler adds the request ID and current span of the record's
+// context.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := requestid.From(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
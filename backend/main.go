@@ -2,58 +2,92 @@ package main
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
 
 	_ "github.com/lib/pq"
-	// "github.com/mdarify1337/backend-go/backend/controllers"
-	"github.com/mdarify1337/backend-go/backend/migrations"
-	"github.com/mdarify1337/backend-go/backend/services"
+
+	"github.com/mdarify1337/backend-go/backend/config"
+	"github.com/mdarify1337/backend-go/backend/logging"
 )
 
-func enableCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000") // fixed missing colon
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == http.MethodOptions {
-			log.Println("[CORS] Preflight request handled")
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		log.Printf("[CORS] Passing request %s %s\n", r.Method, r.URL.Path)
-		next.ServeHTTP(w, r)
-	})
-}
+const usage = `Usage: backend <command> [arguments]
+
+Commands:
+  serve [flags]             run migrations and start the HTTP server (default)
+  config [flags]            print the effective configuration, secrets redacted
+  migrate up                apply all pending migrations
+  migrate down [N]          roll back the last N migrations (default 1)
+  migrate status            list pending, applied and dirty migrations
+  migrate redo              roll back and re-apply the latest migration
+  migrate create <name>     write a new empty up/down SQL pair
+  migrate force <version>   mark the ledger as clean at <version>
+  roles grant <id> <role>   give a role to a user (admin, staff, customer)
+  roles revoke <id> <role>  take a role away from a user
+
+Settings come from built-in defaults, then the YAML file named by -config or
+CONFIG_FILE, then environment variables (each also readable from the file
+named by NAME_FILE), then flags. Run "backend serve -h" for the flags.
+`
 
 func main() {
-	// Get env variables from docker-compose
-	dbHost := os.Getenv("DATABASE_HOST")
-	dbPort := os.Getenv("DATABASE_PORT")
-	dbUser := os.Getenv("DATABASE_USER")
-	dbPassword := os.Getenv("DATABASE_PASSWORD")
-	dbName := os.Getenv("DATABASE_NAME")
-
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		dbHost, dbPort, dbUser, dbPassword, dbName)
-	log.Println("[DB] Connecting to:", dsn)
-	db, err := sql.Open("postgres", dsn)
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	var err error
+	switch args[0] {
+	case "serve":
+		err = runServe(args[1:])
+	case "config":
+		err = runConfig(args[1:])
+	case "migrate":
+		err = runMigrate(args[1:])
+	case "roles":
+		err = runRoles(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig builds the configuration and sets up logging from it. Commands
+// without configuration flags pass a nil fs.
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg, err := config.Load(fs, args)
 	if err != nil {
-		log.Fatal("[DB] Failed to connect:", err)
+		return nil, err
 	}
-	defer db.Close()
-	log.Println("[DB] Connection established")
-	if err := migrations.RunAll(db); err != nil {
-		log.Fatal("[DB] Migration failed:", err)
+	logging.Setup(cfg.Log)
+	return cfg, nil
+}
+
+// runConfig prints the configuration serve would run with.
+func runConfig(args []string) error {
+	cfg, err := loadConfig(flag.NewFlagSet("config", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	cfg.Print(os.Stdout)
+	return nil
+}
+
+func openDB(cfg config.Database) (*sql.DB, error) {
+	slog.Info("connecting to database", "host", cfg.Host, "port", cfg.Port, "user", cfg.User, "name", cfg.Name)
+	db, err := sql.Open("postgres", cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("[DB] Failed to connect: %w", err)
 	}
-	log.Println("[DB] ✅ All tables are ready")
-	mux := http.NewServeMux()
-	services.RunAllServices(mux, db)
-	handler := enableCORS(mux)
-	log.Println("🚀 Go backend running on port 3001")
-	log.Fatal(http.ListenAndServe(":3001", handler))
+	slog.Info("database pool ready")
+	return db, nil
 }
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/mdarify1337/backend-go/backend/migrations"
+)
+
+// RegisterDBStats exposes the connection pool statistics of db as the
+// go_sql_* metrics, labelled with dbName.
+func RegisterDBStats(db *sql.DB, dbName string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterMigrations exposes the applied and expected schema versions. The
+// applied one is read on every scrape, within timeout, and is NaN when the
+// database cannot tell.
+func RegisterMigrations(m *migrations.Migrator, timeout time.Duration) {
+	Factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "schema_migration_version",
+		Help: "Highest applied migration version.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		version, _, err := m.Current(ctx)
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(version)
+	})
+	Factory.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "schema_migration_expected_version",
+		Help: "Migration version this binary expects.",
+	}, func() float64 { return float64(m.Latest()) })
+}
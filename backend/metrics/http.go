@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mdarify1337/backend-go/backend/httpx"
+)
+
+var (
+	httpRequests = Factory.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = Factory.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Time spent serving HTTP requests, by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	httpInFlight = Factory.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+)
+
+// Instrument records every request passed to next under the route pattern
+// httpx.Resolve found for it. Requests matching no pattern share the route
+// "unmatched", so scanners cannot blow up the number of series.
+func Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := httpx.Route(r.Context())
+		if route == "" {
+			route = "unmatched"
+		}
+
+		httpInFlight.Inc()
+		defer httpInFlight.Dec()
+		start := time.Now()
+		rec := httpx.Record(w)
+		next.ServeHTTP(rec, r)
+
+		status := rec.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(status)).Inc()
+		httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	})
+}
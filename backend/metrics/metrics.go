@@ -0,0 +1,36 @@
+// Package metrics holds the Prometheus registry of the service and the
+// collectors the rest of the code reports to. Besides the HTTP, database and
+// domain metrics it exports the Go runtime and process collectors.
+//
+// Metrics are created through Factory, so they are usually package-level
+// variables registered with Registry as soon as they exist.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry is what Handler serves. It is a registry of its own rather than
+// the client library's global one so only metrics this service defines are
+// exported.
+var Registry = prometheus.NewRegistry()
+
+// Factory creates metrics registered with Registry.
+var Factory = promauto.With(Registry)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
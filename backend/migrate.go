@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mdarify1337/backend-go/backend/migrations"
+)
+
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := fs.String("dir", migrations.SQLDir, "directory for migrate create")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.Parse(args)
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// create only touches the filesystem
+	if args[0] == "create" {
+		if len(args) != 2 {
+			return errors.New("usage: migrate create <name>")
+		}
+		up, down, err := migrations.Create(*dir, args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return nil
+	}
+
+	cfg, err := loadConfig(nil, nil)
+	if err != nil {
+		return err
+	}
+	db, err := openDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+		}
+		err = m.Down(ctx, n)
+	case "redo":
+		err = m.Redo(ctx)
+	case "force":
+		if len(args) != 2 {
+			return errors.New("usage: migrate force <version>")
+		}
+		version, perr := strconv.ParseInt(args[1], 10, 64)
+		if perr != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		err = m.Force(ctx, version)
+	case "status":
+		return printStatus(ctx, m)
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		return err
+	}
+	return printStatus(ctx, m)
+}
+
+func printStatus(ctx context.Context, m *migrations.Migrator) error {
+	report, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, s := range report {
+		appliedAt := "-"
+		if s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.UTC().Format("2006-01-02 15:04:05Z")
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+	}
+	return tw.Flush()
+}
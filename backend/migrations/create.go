@@ -0,0 +1,62 @@
+// backend/migrations/create.go
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes an empty up/down SQL pair to dir, numbered one past the
+// highest version found there, and returns the two file paths.
+func Create(dir, name string) (string, string, error) {
+	slug := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if slug == "" {
+		return "", "", fmt.Errorf("invalid migration name %q", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var latest int64
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		if v, _ := strconv.ParseInt(match[1], 10, 64); v > latest {
+			latest = v
+		}
+	}
+	for _, r := range registered {
+		if r.Version > latest {
+			latest = r.Version
+		}
+	}
+
+	base := fmt.Sprintf("%04d_%s", latest+1, slug)
+	up := filepath.Join(dir, base+".up.sql")
+	down := filepath.Join(dir, base+".down.sql")
+	files := map[string]string{
+		up:   fmt.Sprintf("-- %s: apply\n", base),
+		down: fmt.Sprintf("-- %s: roll back\n", base),
+	}
+	for p, body := range files {
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to create %s: %w", p, err)
+		}
+		_, err = f.WriteString(body)
+		f.Close()
+		if err != nil {
+			return "", "", fmt.Errorf("failed to write %s: %w", p, err)
+		}
+	}
+	return up, down, nil
+}
@@ -0,0 +1,59 @@
+// backend/migrations/hash_passwords.go
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	"github.com/mdarify1337/backend-go/backend/password"
+)
+
+func init() {
+	Register(Migration{
+		Version:  3,
+		Name:     "hash_plaintext_passwords",
+		Revision: 1,
+		UpFunc:   hashPlaintextPasswords,
+		DownFunc: func(context.Context, *sql.Tx) error { return nil }, // hashes cannot be reversed
+	})
+}
+
+// hashPlaintextPasswords replaces every password stored before hashing was
+// introduced with its argon2id hash.
+func hashPlaintextPasswords(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, password FROM users FOR UPDATE;`)
+	if err != nil {
+		return fmt.Errorf("failed to read users: %w", err)
+	}
+	plain := map[int]string{}
+	for rows.Next() {
+		var id int
+		var pw string
+		if err := rows.Scan(&id, &pw); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan user: %w", err)
+		}
+		if !password.IsHashed(pw) {
+			plain[id] = pw
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, pw := range plain {
+		hash, err := password.Hash(pw)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE users SET password = $1 WHERE id = $2;`, hash, id); err != nil {
+			return fmt.Errorf("failed to update user %d: %w", id, err)
+		}
+	}
+	slog.Info("hashed plaintext passwords", "count", len(plain))
+	return nil
+}
@@ -0,0 +1,139 @@
+// backend/migrations/migration.go
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a single numbered schema change. It is either backed by a
+// pair of SQL files under sql/ or by Go functions registered with Register.
+type Migration struct {
+	Version int64
+	Name    string
+
+	UpSQL   string
+	DownSQL string
+
+	UpFunc   func(ctx context.Context, tx *sql.Tx) error
+	DownFunc func(ctx context.Context, tx *sql.Tx) error
+	// Revision stands in for the SQL in the checksum of a Go migration,
+	// whose code cannot be hashed. Start at 1 and bump it whenever UpFunc or
+	// DownFunc change meaning, so databases that ran the old code are
+	// flagged.
+	Revision int
+
+	// NoTx runs the migration outside a transaction, for statements such as
+	// CREATE INDEX CONCURRENTLY. A failure leaves the version marked dirty.
+	NoTx bool
+}
+
+// Checksum identifies the SQL of a migration, or the version, name and
+// revision of a Go one, so edits made after it was applied can be detected.
+func (m Migration) Checksum() string {
+	content := m.UpSQL + "\x00" + m.DownSQL
+	if m.isGo() {
+		content = fmt.Sprintf("go\x00%d\x00%s\x00%d", m.Version, m.Name, m.Revision)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) isGo() bool {
+	return m.UpFunc != nil || m.DownFunc != nil
+}
+
+// HasDown reports whether the migration can be rolled back.
+func (m Migration) HasDown() bool {
+	return m.DownSQL != "" || m.DownFunc != nil
+}
+
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+// SQLDir is where SQL migration files live, relative to the backend module.
+const SQLDir = "migrations/sql"
+
+// noTxDirective marks an SQL migration that must not run in a transaction.
+const noTxDirective = "-- +migrate notx"
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+var registered []Migration
+
+// Register adds a Go migration to the set applied by RunAll. It is meant to
+// be called from init functions in this package.
+func Register(m Migration) {
+	registered = append(registered, m)
+}
+
+// All returns every known migration sorted by version.
+func All() ([]Migration, error) {
+	byVersion := map[int64]*Migration{}
+
+	entries, err := fs.ReadDir(sqlFiles, "sql")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration files: %w", err)
+	}
+	for _, entry := range entries {
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		body, err := sqlFiles.ReadFile(path.Join("sql", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q",
+				version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(body)
+			m.NoTx = strings.HasPrefix(m.UpSQL, noTxDirective)
+		} else {
+			m.DownSQL = string(body)
+		}
+	}
+
+	for _, r := range registered {
+		if _, ok := byVersion[r.Version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d", r.Version)
+		}
+		r := r
+		byVersion[r.Version] = &r
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.UpSQL == "" && m.UpFunc == nil {
+			return nil, fmt.Errorf("migration %d_%s has no up step", m.Version, m.Name)
+		}
+		if m.isGo() && m.Revision < 1 {
+			return nil, fmt.Errorf("migration %d_%s: Go migrations need a Revision", m.Version, m.Name)
+		}
+		if m.NoTx && m.isGo() {
+			return nil, fmt.Errorf("migration %d_%s: only SQL migrations can run without a transaction",
+				m.Version, m.Name)
+		}
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all, nil
+}
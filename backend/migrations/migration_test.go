@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func noop(context.Context, *sql.Tx) error { return nil }
+
+func TestGoMigrationChecksum(t *testing.T) {
+	a := Migration{Version: 3, Name: "a", Revision: 1, UpFunc: noop}
+	b := Migration{Version: 7, Name: "b", Revision: 1, UpFunc: noop}
+	if a.Checksum() == b.Checksum() {
+		t.Error("different Go migrations share a checksum")
+	}
+	bumped := a
+	bumped.Revision = 2
+	if a.Checksum() == bumped.Checksum() {
+		t.Error("bumping Revision kept the checksum")
+	}
+
+	all, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]int64{}
+	for _, m := range all {
+		if v, ok := seen[m.Checksum()]; ok {
+			t.Errorf("migrations %d and %d share a checksum", v, m.Version)
+		}
+		seen[m.Checksum()] = m.Version
+	}
+}
+
+func TestGoMigrationNeedsRevision(t *testing.T) {
+	saved := registered
+	t.Cleanup(func() { registered = saved })
+	Register(Migration{Version: 9999, Name: "unrevised", UpFunc: noop})
+	if _, err := All(); err == nil {
+		t.Error("All accepted a Go migration without a Revision")
+	}
+}
+
+func TestVerifyRevision(t *testing.T) {
+	applied := Migration{Version: 3, Name: "a", Revision: 1, UpFunc: noop}
+	ledger := map[int64]ledgerRow{3: {name: "a", checksum: applied.Checksum()}}
+
+	m := &Migrator{migrations: []Migration{applied}}
+	if err := m.verify(ledger); err != nil {
+		t.Fatalf("unchanged migration: %v", err)
+	}
+	applied.Revision = 2
+	m = &Migrator{migrations: []Migration{applied}}
+	if err := m.verify(ledger); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("revised migration: err = %v, want ErrChecksumMismatch", err)
+	}
+}
@@ -0,0 +1,426 @@
+// backend/migrations/migrator.go
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// lockKey is the Postgres advisory lock held while migrating, so several
+// backend replicas starting at once apply each migration exactly once.
+const lockKey int64 = 0x6d6967726174 // "migrat"
+
+var (
+	ErrDirty            = errors.New("database is in a dirty migration state")
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrNoDown           = errors.New("migration cannot be rolled back")
+)
+
+const createLedger = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		dirty BOOLEAN NOT NULL DEFAULT false,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+`
+
+// State describes where a migration stands against the ledger.
+type State string
+
+const (
+	StatePending State = "pending"
+	StateApplied State = "applied"
+	StateDirty   State = "dirty"
+	StateChanged State = "changed"
+	StateMissing State = "missing" // applied, but unknown to this binary
+)
+
+// Status is one line of the migration report.
+type Status struct {
+	Version   int64
+	Name      string
+	State     State
+	AppliedAt *time.Time
+}
+
+type ledgerRow struct {
+	name      string
+	checksum  string
+	dirty     bool
+	appliedAt time.Time
+}
+
+// Migrator applies and rolls back migrations against a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for every migration known to this binary.
+func New(db *sql.DB) (*Migrator, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: all}, nil
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration in order.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]ledgerRow) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the n most recently applied migrations.
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]ledgerRow) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && n > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			n--
+		}
+		return nil
+	})
+}
+
+// Redo rolls back the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]ledgerRow) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			return m.apply(ctx, conn, mig, true)
+		}
+		return nil
+	})
+}
+
+// Force rewrites the ledger so that exactly the migrations up to and
+// including version are recorded as applied and clean, without running any
+// SQL. It is the way out of a dirty state after fixing the schema by hand.
+func (m *Migrator) Force(ctx context.Context, version int64) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[int64]ledgerRow) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM schema_migrations WHERE version > $1;`, version); err != nil {
+			return fmt.Errorf("failed to clear ledger: %w", err)
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name, checksum, dirty)
+				VALUES ($1, $2, $3, false)
+				ON CONFLICT (version) DO UPDATE
+				SET name = EXCLUDED.name, checksum = EXCLUDED.checksum, dirty = false;
+			`, mig.Version, mig.Name, mig.Checksum())
+			if err != nil {
+				return fmt.Errorf("failed to force version %d: %w", mig.Version, err)
+			}
+		}
+		return tx.Commit()
+	})
+}
+
+// Status reports every known and every applied migration. It does not wait
+// for the migration lock, so it works while another process migrates.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.snapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var report []Status
+	known := map[int64]bool{}
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name, State: StatePending}
+		if row, ok := applied[mig.Version]; ok {
+			at := row.appliedAt
+			s.AppliedAt = &at
+			switch {
+			case row.dirty:
+				s.State = StateDirty
+			case row.checksum != mig.Checksum():
+				s.State = StateChanged
+			default:
+				s.State = StateApplied
+			}
+		}
+		report = append(report, s)
+	}
+	for version, row := range applied {
+		if known[version] {
+			continue
+		}
+		at := row.appliedAt
+		state := StateMissing
+		if row.dirty {
+			state = StateDirty
+		}
+		report = append(report, Status{Version: version, Name: row.name,
+			State: state, AppliedAt: &at})
+	}
+	return report, nil
+}
+
+// Version returns the highest applied version and whether any migration is
+// dirty. A database without a ledger is at version 0.
+func (m *Migrator) Version(ctx context.Context) (int64, bool, error) {
+	applied, err := m.snapshot(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	var version int64
+	var dirty bool
+	for v, row := range applied {
+		if v > version {
+			version = v
+		}
+		dirty = dirty || row.dirty
+	}
+	return version, dirty, nil
+}
+
+// Current returns the highest applied version and whether any migration is
+// dirty, in a single query for probes; it fails when the ledger is missing.
+func (m *Migrator) Current(ctx context.Context) (int64, bool, error) {
+	var version int64
+	var dirty bool
+	err := m.db.QueryRowContext(ctx, `
+		SELECT COALESCE(max(version), 0), COALESCE(bool_or(dirty), false)
+		FROM schema_migrations;
+	`).Scan(&version, &dirty)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// snapshot reads the ledger without taking the migration lock or creating
+// the table, so reports never wait behind a running migration. While one
+// runs they show the migrations committed so far.
+func (m *Migrator) snapshot(ctx context.Context) (map[int64]ledgerRow, error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx,
+		`SELECT to_regclass('schema_migrations') IS NOT NULL;`).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to look up schema_migrations: %w", err)
+	}
+	if !exists {
+		return map[int64]ledgerRow{}, nil
+	}
+	return readLedger(ctx, m.db)
+}
+
+// withLock pins a single connection, takes the advisory lock on it, makes
+// sure the ledger exists and hands the currently applied rows to fn.
+func (m *Migrator) withLock(ctx context.Context,
+	fn func(conn *sql.Conn, applied map[int64]ledgerRow) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1);`, lockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1);`, lockKey)
+
+	if _, err := conn.ExecContext(ctx, createLedger); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	applied, err := readLedger(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return fn(conn, applied)
+}
+
+// querier is what readLedger needs from a *sql.DB or *sql.Conn.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func readLedger(ctx context.Context, q querier) (map[int64]ledgerRow, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT version, name, checksum, dirty, applied_at FROM schema_migrations;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]ledgerRow{}
+	for rows.Next() {
+		var version int64
+		var row ledgerRow
+		if err := rows.Scan(&version, &row.name, &row.checksum,
+			&row.dirty, &row.appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_migrations: %w", err)
+		}
+		applied[version] = row
+	}
+	return applied, rows.Err()
+}
+
+// verify refuses to continue when the ledger is dirty or when a migration
+// that was already applied has since been edited.
+func (m *Migrator) verify(applied map[int64]ledgerRow) error {
+	for _, mig := range m.migrations {
+		row, ok := applied[mig.Version]
+		if !ok {
+			continue
+		}
+		if row.dirty {
+			return fmt.Errorf("%w: version %d (%s), fix the schema and run migrate force",
+				ErrDirty, mig.Version, mig.Name)
+		}
+		if row.checksum != mig.Checksum() {
+			return fmt.Errorf("%w: version %d (%s)", ErrChecksumMismatch,
+				mig.Version, mig.Name)
+		}
+	}
+	for version, row := range applied {
+		if row.dirty {
+			return fmt.Errorf("%w: version %d (%s)", ErrDirty, version, row.name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	direction := "up"
+	if !up {
+		direction = "down"
+		if !mig.HasDown() {
+			return fmt.Errorf("%w: version %d (%s)", ErrNoDown, mig.Version, mig.Name)
+		}
+	}
+	slog.Info("migrating", "direction", direction, "version", mig.Version, "name", mig.Name)
+
+	var err error
+	if mig.NoTx {
+		err = m.applyNoTx(ctx, conn, mig, up)
+	} else {
+		err = m.applyTx(ctx, conn, mig, up)
+	}
+	if err != nil {
+		return fmt.Errorf("migration %d_%s %s failed: %w", mig.Version, mig.Name, direction, err)
+	}
+	return nil
+}
+
+func (m *Migrator) applyTx(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if up {
+		if err := runStep(ctx, tx, mig.UpSQL, mig.UpFunc); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version, name, checksum)
+			VALUES ($1, $2, $3);
+		`, mig.Version, mig.Name, mig.Checksum())
+	} else {
+		if err := runStep(ctx, tx, mig.DownSQL, mig.DownFunc); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM schema_migrations WHERE version = $1;`, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// applyNoTx marks the version dirty before running the SQL and clears the
+// mark afterwards, so a failure half way through is visible in the ledger.
+func (m *Migrator) applyNoTx(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	if up {
+		if _, err := conn.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version, name, checksum, dirty)
+			VALUES ($1, $2, $3, true);
+		`, mig.Version, mig.Name, mig.Checksum()); err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(ctx, mig.UpSQL); err != nil {
+			return err
+		}
+		_, err := conn.ExecContext(ctx,
+			`UPDATE schema_migrations SET dirty = false WHERE version = $1;`, mig.Version)
+		return err
+	}
+
+	if _, err := conn.ExecContext(ctx,
+		`UPDATE schema_migrations SET dirty = true WHERE version = $1;`, mig.Version); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, mig.DownSQL); err != nil {
+		return err
+	}
+	_, err := conn.ExecContext(ctx,
+		`DELETE FROM schema_migrations WHERE version = $1;`, mig.Version)
+	return err
+}
+
+func runStep(ctx context.Context, tx *sql.Tx, query string,
+	fn func(ctx context.Context, tx *sql.Tx) error) error {
+	if fn != nil {
+		return fn(ctx, tx)
+	}
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
@@ -1,13 +1,17 @@
 // backend/migrations/registry.go
 package migrations
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
+// RunAll applies every pending migration. It is safe to call from several
+// processes at once; they serialise on an advisory lock.
 func RunAll(db *sql.DB) error {
-	if err := CreateUsersTable(db); err != nil {
+	m, err := New(db)
+	if err != nil {
 		return err
 	}
-	// add more like: if err := CreateMeetingsTable(db);
-	// err != nil { return err }
-	return nil
+	return m.Up(context.Background())
 }
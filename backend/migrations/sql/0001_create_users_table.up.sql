@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	username VARCHAR(50) NOT NULL,
+	email VARCHAR(100) NOT NULL,
+	password VARCHAR(255) NOT NULL,
+	first_name VARCHAR(50),
+	last_name VARCHAR(50),
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP,
+	picture TEXT
+);
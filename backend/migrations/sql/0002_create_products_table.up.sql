@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	description TEXT,
+	price DECIMAL(10, 2) NOT NULL,
+	quantity INT NOT NULL,
+	created_at TIMESTAMP,
+	updated_at TIMESTAMP,
+	user_id INT REFERENCES users(id) ON DELETE CASCADE
+);
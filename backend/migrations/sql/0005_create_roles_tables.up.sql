@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS roles (
+	name VARCHAR(50) PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO roles (name, description) VALUES
+	('admin', 'Full access to every resource'),
+	('staff', 'Manages any product'),
+	('customer', 'Manages their own account and products')
+ON CONFLICT (name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS user_roles (
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+	granted_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO user_roles (user_id, role)
+SELECT id, 'customer' FROM users
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS products_deleted_at_idx;
+ALTER TABLE products DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS products_name_trgm_idx;
+DROP INDEX IF EXISTS products_search_idx;
+DROP TRIGGER IF EXISTS products_search_update ON products;
+DROP FUNCTION IF EXISTS products_search_update();
+ALTER TABLE products DROP COLUMN IF EXISTS search;
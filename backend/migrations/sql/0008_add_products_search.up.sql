@@ -0,0 +1,25 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE products ADD COLUMN IF NOT EXISTS search tsvector;
+
+-- Name matches outrank description matches
+CREATE OR REPLACE FUNCTION products_search_update() RETURNS trigger AS $$
+BEGIN
+	NEW.search :=
+		setweight(to_tsvector('english', coalesce(NEW.name, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(NEW.description, '')), 'B');
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_search_update ON products;
+CREATE TRIGGER products_search_update
+	BEFORE INSERT OR UPDATE OF name, description ON products
+	FOR EACH ROW EXECUTE FUNCTION products_search_update();
+
+UPDATE products SET search =
+	setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+	setweight(to_tsvector('english', coalesce(description, '')), 'B');
+
+CREATE INDEX IF NOT EXISTS products_search_idx ON products USING GIN (search);
+CREATE INDEX IF NOT EXISTS products_name_trgm_idx ON products USING GIN (name gin_trgm_ops);
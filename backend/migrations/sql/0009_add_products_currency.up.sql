@@ -0,0 +1,4 @@
+ALTER TABLE products ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE products DROP CONSTRAINT IF EXISTS products_currency_check;
+ALTER TABLE products ADD CONSTRAINT products_currency_check CHECK (currency ~ '^[A-Z]{3}$');
@@ -0,0 +1,44 @@
+-- Existing values were written as server-local wall time into TIMESTAMP
+-- columns; the server has always run in UTC, so read them as UTC.
+ALTER TABLE users
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+ALTER TABLE products
+	ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+	ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+UPDATE users SET created_at = coalesce(created_at, updated_at, now()),
+	updated_at = coalesce(updated_at, created_at, now())
+	WHERE created_at IS NULL OR updated_at IS NULL;
+UPDATE products SET created_at = coalesce(created_at, updated_at, now()),
+	updated_at = coalesce(updated_at, created_at, now())
+	WHERE created_at IS NULL OR updated_at IS NULL;
+
+ALTER TABLE users
+	ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL,
+	ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+ALTER TABLE products
+	ALTER COLUMN created_at SET DEFAULT now(), ALTER COLUMN created_at SET NOT NULL,
+	ALTER COLUMN updated_at SET DEFAULT now(), ALTER COLUMN updated_at SET NOT NULL;
+
+-- updated_at is owned by the database: any change to a row bumps it, and
+-- created_at cannot be rewritten
+CREATE OR REPLACE FUNCTION set_updated_at() RETURNS trigger AS $$
+BEGIN
+	IF NEW IS DISTINCT FROM OLD THEN
+		NEW.created_at := OLD.created_at;
+		NEW.updated_at := now();
+	END IF;
+	RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_set_updated_at ON users;
+CREATE TRIGGER users_set_updated_at
+	BEFORE UPDATE ON users
+	FOR EACH ROW EXECUTE FUNCTION set_updated_at();
+
+DROP TRIGGER IF EXISTS products_set_updated_at ON products;
+CREATE TRIGGER products_set_updated_at
+	BEFORE UPDATE ON products
+	FOR EACH ROW EXECUTE FUNCTION set_updated_at();
@@ -0,0 +1,160 @@
+// backend/migrations/unique_users.go
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+func init() {
+	Register(Migration{
+		Version:  7,
+		Name:     "unique_user_email_username",
+		Revision: 1,
+		UpFunc:   uniqueUsersUp,
+		DownFunc: uniqueUsersDown,
+	})
+}
+
+// uniqueUsersUp renames the case-insensitive duplicates that piled up while
+// the table had no constraints, then adds the unique indexes. The oldest
+// account in each group keeps its value; every rename is logged so support
+// can reach the affected users.
+func uniqueUsersUp(ctx context.Context, tx *sql.Tx) error {
+	columns := []struct {
+		name   string
+		rename func(value string, id, attempt int) string
+	}{
+		{"email", renameEmail},
+		{"username", renameUsername},
+	}
+	for _, c := range columns {
+		if err := renameDuplicates(ctx, tx, c.name, c.rename); err != nil {
+			return fmt.Errorf("failed to resolve duplicate %ss: %w", c.name, err)
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
+		CREATE UNIQUE INDEX users_username_lower_key ON users (lower(username));
+	`)
+	return err
+}
+
+// renameDuplicates gives every user whose column clashes with an older
+// account's the first renamed value no other row holds, ignoring case.
+func renameDuplicates(ctx context.Context, tx *sql.Tx, column string,
+	rename func(value string, id, attempt int) string) error {
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, keep_id, %[1]s FROM (
+			SELECT id, %[1]s, min(id) OVER (PARTITION BY lower(%[1]s)) AS keep_id
+			FROM users
+		) d
+		WHERE id <> keep_id
+		ORDER BY id;
+	`, column))
+	if err != nil {
+		return err
+	}
+	type duplicate struct {
+		id, keep int
+		value    string
+	}
+	var dups []duplicate
+	for rows.Next() {
+		var d duplicate
+		if err := rows.Scan(&d.id, &d.keep, &d.value); err != nil {
+			rows.Close()
+			return err
+		}
+		dups = append(dups, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, d := range dups {
+		var renamed string
+		for attempt := 0; ; attempt++ {
+			renamed = rename(d.value, d.id, attempt)
+			var taken bool
+			err := tx.QueryRowContext(ctx, fmt.Sprintf(
+				`SELECT EXISTS (SELECT 1 FROM users WHERE lower(%s) = lower($1));`, column),
+				renamed).Scan(&taken)
+			if err != nil {
+				return err
+			}
+			if !taken {
+				break
+			}
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+			`UPDATE users SET %s = $1 WHERE id = $2;`, column), renamed, d.id); err != nil {
+			return err
+		}
+		slog.Info("renamed duplicate user", "column", column, "user_id", d.id,
+			"clashes_with", d.keep, "renamed_to", renamed)
+	}
+	return nil
+}
+
+// Column sizes from 0001_create_users_table, in characters.
+const (
+	maxEmail    = 100
+	maxUsername = 50
+)
+
+// renameEmail tags the local part: jdoe@example.com -> jdoe+dup42@example.com,
+// then jdoe+dup42-1@example.com and so on if that is taken too. The local
+// part is shortened to keep the result within the column.
+func renameEmail(email string, id, attempt int) string {
+	local, domain := email, ""
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		local, domain = email[:at], email[at:]
+	}
+	tag := dupTag("+dup", id, attempt)
+	if n := utf8.RuneCountInString(domain); n+len(tag) > maxEmail {
+		domain = truncate(domain, maxEmail-len(tag)) // absurd domain; keep the row valid
+	}
+	local = truncate(local, maxEmail-len(tag)-utf8.RuneCountInString(domain))
+	return local + tag + domain
+}
+
+// renameUsername appends a tag: jdoe -> jdoe_dup42, then jdoe_dup42-1.
+func renameUsername(username string, id, attempt int) string {
+	tag := dupTag("_dup", id, attempt)
+	return truncate(username, maxUsername-len(tag)) + tag
+}
+
+func dupTag(prefix string, id, attempt int) string {
+	tag := prefix + strconv.Itoa(id)
+	if attempt > 0 {
+		tag += "-" + strconv.Itoa(attempt)
+	}
+	return tag
+}
+
+// truncate cuts s to at most n characters.
+func truncate(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if r := []rune(s); len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
+
+func uniqueUsersDown(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `
+		DROP INDEX IF EXISTS users_email_lower_key;
+		DROP INDEX IF EXISTS users_username_lower_key;
+	`)
+	return err
+}
@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenameEmail(t *testing.T) {
+	tests := []struct {
+		email   string
+		id      int
+		attempt int
+		want    string
+	}{
+		{"jdoe@example.com", 42, 0, "jdoe+dup42@example.com"},
+		{"jdoe@example.com", 42, 2, "jdoe+dup42-2@example.com"},
+		{"a@b@example.com", 7, 0, "a@b+dup7@example.com"},
+		{"no-at-sign", 7, 0, "no-at-sign+dup7"},
+		{strings.Repeat("é", 95) + "@x.io", 123456, 0, strings.Repeat("é", 85) + "+dup123456@x.io"},
+	}
+	for _, tt := range tests {
+		got := renameEmail(tt.email, tt.id, tt.attempt)
+		if n := utf8.RuneCountInString(got); n > maxEmail {
+			t.Errorf("renameEmail(%q) has %d characters", tt.email, n)
+		}
+		if got != tt.want {
+			t.Errorf("renameEmail(%q, %d, %d) = %q, want %q", tt.email, tt.id, tt.attempt, got, tt.want)
+		}
+	}
+
+	local := strings.Repeat("a", 88)
+	got := renameEmail(local+"@example.com", 99999, 3)
+	if want := local[:100-len("+dup99999-3@example.com")] + "+dup99999-3@example.com"; got != want {
+		t.Errorf("long local part renamed to %q, want %q", got, want)
+	}
+	if got := renameEmail("x@"+strings.Repeat("d", 98), 5, 0); utf8.RuneCountInString(got) > maxEmail {
+		t.Errorf("long domain renamed to %d characters", utf8.RuneCountInString(got))
+	}
+}
+
+func TestRenameUsername(t *testing.T) {
+	if got := renameUsername("jdoe", 42, 0); got != "jdoe_dup42" {
+		t.Errorf("got %q", got)
+	}
+	if got := renameUsername("jdoe", 42, 1); got != "jdoe_dup42-1" {
+		t.Errorf("got %q", got)
+	}
+	long := strings.Repeat("ü", 50)
+	got := renameUsername(long, 1234, 0)
+	if n := utf8.RuneCountInString(got); n != maxUsername || !strings.HasSuffix(got, "_dup1234") {
+		t.Errorf("got %q with %d characters", got, n)
+	}
+}
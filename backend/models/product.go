@@ -1,12 +1,20 @@
 package models
 
+import (
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/money"
+)
+
 type Product struct {
-	ID          int     `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price"`
-	Quantity    int     `json:"quantity"`
-	CreatedAt   string  `json:"created_at"`
-	UpdatedAt   string  `json:"updated_at"`
-	UserID      int     `json:"user_id"`
+	ID          int          `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`
+	Quantity    int          `json:"quantity"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	UserID      int          `json:"user_id"`
+	DeletedAt   *time.Time   `json:"deleted_at,omitempty"`
 }
@@ -1,16 +1,24 @@
 package models
 
-import ()
+import (
+	"log/slog"
+	"time"
+)
 
 type User struct {
 	ID        int       `json:"id"`
 	Username  string    `json:"username"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password"`
+	Password  string    `json:"-"` // never serialised; use dto.UserResponse
 	FirstName string    `json:"first_name"`
 	LastName  string    `json:"last_name"`
-	CreatedAt string    `json:"created_at"`
-	UpdatedAt string    `json:"updated_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 	Picture   string    `json:"picture"`
 	Products  []Product `json:"products,omitempty"`
 }
+
+// LogValue keeps the password hash and personal details out of logs.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", u.ID), slog.String("username", u.Username))
+}
@@ -0,0 +1,199 @@
+// Package money holds exact decimal amounts.
+//
+// Amounts are stored as an integer number of hundredths, matching the
+// DECIMAL(10,2) price column, so values round-trip through Postgres and
+// JSON without float drift. Input with more than two decimals is rounded
+// half away from zero (19.995 becomes 20.00), the same way Postgres rounds
+// when assigning to a NUMERIC(p,2) column.
+package money
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimals kept.
+const Scale = 2
+
+// DefaultCurrency is used when a product is created without one.
+const DefaultCurrency = "USD"
+
+var (
+	ErrInvalid  = errors.New("invalid amount")
+	ErrOverflow = errors.New("amount out of range")
+)
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency reports whether code looks like an ISO 4217 currency code.
+func ValidCurrency(code string) bool {
+	return currencyPattern.MatchString(code)
+}
+
+// Amount is an exact decimal with two fraction digits.
+type Amount struct {
+	cents int64
+}
+
+// FromCents returns the amount of n hundredths.
+func FromCents(n int64) Amount {
+	return Amount{cents: n}
+}
+
+var hundred = big.NewInt(100)
+
+// Parse reads a decimal such as "19.99", "-3", "1e2" or "0.005" and rounds
+// it to two decimals.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	// Huge exponents would make big.Rat allocate without bound
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		if exp, err := strconv.Atoi(s[i+1:]); err != nil || exp < -30 || exp > 30 {
+			return Amount{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+		}
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || s == "" || strings.ContainsAny(s, "/xXpP") {
+		return Amount{}, fmt.Errorf("%w: %q", ErrInvalid, s)
+	}
+
+	num := new(big.Int).Mul(r.Num(), hundred)
+	den := r.Denom()
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	// Round half away from zero
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return Amount{}, fmt.Errorf("%w: %q", ErrOverflow, s)
+	}
+	return Amount{cents: quo.Int64()}, nil
+}
+
+// MustParse is Parse for constants; it panics on bad input.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Cents returns the amount in hundredths.
+func (a Amount) Cents() int64 { return a.cents }
+
+// IsZero reports whether the amount is zero.
+func (a Amount) IsZero() bool { return a.cents == 0 }
+
+// Add returns a + b.
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a.cents + b.cents
+	if (sum > a.cents) != (b.cents > 0) {
+		return Amount{}, ErrOverflow
+	}
+	return Amount{cents: sum}, nil
+}
+
+// Mul returns a times n, as when pricing a quantity.
+func (a Amount) Mul(n int64) (Amount, error) {
+	if a.cents != 0 && n != 0 {
+		p := a.cents * n
+		if p/n != a.cents || (n == -1 && a.cents == math.MinInt64) {
+			return Amount{}, ErrOverflow
+		}
+		return Amount{cents: p}, nil
+	}
+	return Amount{}, nil
+}
+
+// Cmp returns -1, 0 or 1 as a is less than, equal to or greater than b.
+func (a Amount) Cmp(b Amount) int {
+	switch {
+	case a.cents < b.cents:
+		return -1
+	case a.cents > b.cents:
+		return 1
+	}
+	return 0
+}
+
+// Float64 approximates the amount, for range checks only; never compute
+// with it.
+func (a Amount) Float64() float64 {
+	return float64(a.cents) / 100
+}
+
+// String formats the amount with exactly two decimals, as in "-0.50".
+func (a Amount) String() string {
+	sign := ""
+	u := uint64(a.cents)
+	if a.cents < 0 {
+		sign = "-"
+		u = -u
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, u/100, u%100)
+}
+
+// MarshalJSON encodes the amount as a string so JavaScript clients do not
+// turn it into a float.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(a.String())), nil
+}
+
+// UnmarshalJSON accepts a decimal string or, for older clients, a bare JSON
+// number; both are read as text, never through float64.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		unquoted, err := strconv.Unquote(s)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalid, s)
+		}
+		s = unquoted
+	}
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Scan implements sql.Scanner for NUMERIC columns, which lib/pq returns as
+// text.
+func (a *Amount) Scan(src any) error {
+	var err error
+	switch v := src.(type) {
+	case []byte:
+		*a, err = Parse(string(v))
+	case string:
+		*a, err = Parse(v)
+	case int64:
+		*a, err = FromCents(v).Mul(100)
+	case float64:
+		*a, err = Parse(strconv.FormatFloat(v, 'f', -1, 64))
+	case nil:
+		err = fmt.Errorf("%w: NULL", ErrInvalid)
+	default:
+		err = fmt.Errorf("%w: cannot scan %T", ErrInvalid, src)
+	}
+	return err
+}
+
+// Value implements driver.Valuer, sending the exact decimal text.
+func (a Amount) Value() (driver.Value, error) {
+	return a.String(), nil
+}
@@ -0,0 +1,130 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		cents int64
+		err   error
+	}{
+		{"19.99", 1999, nil},
+		{" -3 ", -300, nil},
+		{"0.005", 1, nil},
+		{"-0.005", -1, nil},
+		{"0.0049", 0, nil},
+		{"19.995", 2000, nil},
+		{"-19.995", -2000, nil},
+		{"1e2", 10000, nil},
+		{"1.5E-1", 15, nil},
+		{"0.123456789", 12, nil},
+		{"92233720368547758.07", math.MaxInt64, nil},
+		{"-92233720368547758.08", math.MinInt64, nil},
+		{"92233720368547758.08", 0, ErrOverflow},
+		{"1e30", 0, ErrOverflow},
+		{"1e31", 0, ErrInvalid},
+		{"1e-31", 0, ErrInvalid},
+		{"1e999999999", 0, ErrInvalid},
+		{"", 0, ErrInvalid},
+		{"abc", 0, ErrInvalid},
+		{"1/3", 0, ErrInvalid},
+		{"0x10", 0, ErrInvalid},
+		{"0x1p4", 0, ErrInvalid},
+		{"NaN", 0, ErrInvalid},
+		{"Inf", 0, ErrInvalid},
+		{"-Infinity", 0, ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if !errors.Is(err, tt.err) || (tt.err == nil && got.Cents() != tt.cents) {
+			t.Errorf("Parse(%q) = %d, %v; want %d, %v", tt.in, got.Cents(), err, tt.cents, tt.err)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in    string
+		cents int64
+		err   bool
+	}{
+		{`"19.99"`, 1999, false},
+		{`19.99`, 1999, false},
+		{`-0.5`, -50, false},
+		{`"0.005"`, 1, false},
+		{`null`, 4200, false}, // leaves the previous value
+		{`"abc"`, 0, true},
+		{`"1e999"`, 0, true},
+		{`true`, 0, true},
+	}
+	for _, tt := range tests {
+		a := FromCents(4200)
+		err := json.Unmarshal([]byte(tt.in), &a)
+		if (err != nil) != tt.err || (!tt.err && a.Cents() != tt.cents) {
+			t.Errorf("Unmarshal(%s) = %d, %v; want %d, error %v", tt.in, a.Cents(), err, tt.cents, tt.err)
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src   any
+		cents int64
+		err   bool
+	}{
+		{[]byte("19.99"), 1999, false},
+		{[]byte("-0.50"), -50, false},
+		{"7.10", 710, false},
+		{int64(12), 1200, false},
+		{int64(-3), -300, false},
+		{int64(math.MaxInt64), 0, true},
+		{float64(2.5), 250, false},
+		{[]byte("x"), 0, true},
+		{nil, 0, true},
+		{true, 0, true},
+	}
+	for _, tt := range tests {
+		var a Amount
+		err := a.Scan(tt.src)
+		if (err != nil) != tt.err || (!tt.err && a.Cents() != tt.cents) {
+			t.Errorf("Scan(%#v) = %d, %v; want %d, error %v", tt.src, a.Cents(), err, tt.cents, tt.err)
+		}
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		cents int64
+		want  string
+	}{
+		{0, "0.00"},
+		{5, "0.05"},
+		{-50, "-0.50"},
+		{1999, "19.99"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+	for _, tt := range tests {
+		a := FromCents(tt.cents)
+		if got := a.String(); got != tt.want {
+			t.Errorf("String(%d) = %q, want %q", tt.cents, got, tt.want)
+		}
+		if back, err := Parse(a.String()); err != nil || back != a {
+			t.Errorf("Parse(String(%d)) = %d, %v", tt.cents, back.Cents(), err)
+		}
+
+		data, err := json.Marshal(a)
+		if err != nil || string(data) != `"`+tt.want+`"` {
+			t.Errorf("Marshal(%d) = %s, %v", tt.cents, data, err)
+		}
+		var back Amount
+		if err := json.Unmarshal(data, &back); err != nil || back != a {
+			t.Errorf("Unmarshal(%s) = %d, %v", data, back.Cents(), err)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Package paging implements keyset pagination for list endpoints.
+//
+// A page is requested with ?limit=&sort=&cursor=. The cursor is opaque to
+// clients: it holds the sort key and ID of the last row served, bound to the
+// sort order and filters of the query, and is signed so it cannot be forged
+// or replayed against a different query.
+package paging
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// Cursor marks the position after which the next page starts.
+type Cursor struct {
+	Sort   string `json:"s"`
+	Value  string `json:"v"`
+	ID     int    `json:"i"`
+	Filter string `json:"f"`
+}
+
+// Codec signs and verifies cursors.
+type Codec struct {
+	key []byte
+}
+
+// NewCodec returns a Codec signing with key.
+func NewCodec(key []byte) *Codec {
+	return &Codec{key: key}
+}
+
+// ErrNoSecret is returned by CodecFromSecret when no secret is configured
+// and an ephemeral key is not allowed.
+var ErrNoSecret = errors.New("paging: no cursor secret configured; set CURSOR_SECRET, or DEV_MODE for a throwaway key")
+
+// CodecFromSecret signs with secret. An empty secret is an error unless
+// ephemeral allows a random key, with which cursors stop working across
+// restarts and replicas.
+func CodecFromSecret(secret string, ephemeral bool) (*Codec, error) {
+	if secret != "" {
+		return NewCodec([]byte(secret)), nil
+	}
+	if !ephemeral {
+		return nil, ErrNoSecret
+	}
+	slog.Warn("no cursor secret configured, using an ephemeral key")
+	return EphemeralCodec(), nil
+}
+
+// EphemeralCodec signs with a random key.
+func EphemeralCodec() *Codec {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return NewCodec(key)
+}
+
+var b64 = base64.RawURLEncoding
+
+// Encode returns the opaque form of c.
+func (c *Codec) Encode(cur Cursor) string {
+	payload, _ := json.Marshal(cur)
+	return b64.EncodeToString(payload) + "." + b64.EncodeToString(c.sign(payload))
+}
+
+// Decode verifies and unpacks a cursor produced by Encode.
+func (c *Codec) Decode(s string) (Cursor, bool) {
+	payloadPart, sigPart, ok := strings.Cut(s, ".")
+	if !ok {
+		return Cursor{}, false
+	}
+	payload, err1 := b64.DecodeString(payloadPart)
+	sig, err2 := b64.DecodeString(sigPart)
+	if err1 != nil || err2 != nil || !hmac.Equal(sig, c.sign(payload)) {
+		return Cursor{}, false
+	}
+	var cur Cursor
+	if err := json.Unmarshal(payload, &cur); err != nil {
+		return Cursor{}, false
+	}
+	return cur, true
+}
+
+func (c *Codec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Request is a parsed page request.
+type Request struct {
+	Limit  int
+	Sort   string // whitelisted field name, without the "-" prefix
+	Desc   bool
+	After  *Cursor
+	filter string
+}
+
+// Parse reads limit, sort and cursor from q. sorts lists the allowed sort
+// fields, defaultSort is used when none is given ("-created_at" sorts
+// newest first) and filters names the query parameters the cursor is bound
+// to. scope binds the cursor to values from outside the query as well, such
+// as the owner in /v1/users/{id}/products. Invalid input yields a
+// validation *apperr.Error.
+func Parse(q url.Values, codec *Codec, sorts []string, defaultSort string, filters []string, scope ...string) (Request, error) {
+	var errs []apperr.FieldError
+	req := Request{Limit: DefaultLimit}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxLimit {
+			errs = append(errs, apperr.FieldError{Parameter: "limit", Code: "range",
+				Detail: fmt.Sprintf("must be between 1 and %d", MaxLimit)})
+		} else {
+			req.Limit = n
+		}
+	}
+
+	sortParam := q.Get("sort")
+	if sortParam == "" {
+		sortParam = defaultSort
+	}
+	req.Desc = strings.HasPrefix(sortParam, "-")
+	req.Sort = strings.TrimPrefix(sortParam, "-")
+	if !contains(sorts, req.Sort) {
+		errs = append(errs, apperr.FieldError{Parameter: "sort", Code: "enum",
+			Detail: "must be one of " + strings.Join(sorts, ", ") + ", optionally prefixed with -"})
+	}
+
+	req.filter = digest(q, filters, scope)
+	if v := q.Get("cursor"); v != "" {
+		cur, ok := codec.Decode(v)
+		if !ok {
+			errs = append(errs, apperr.FieldError{Parameter: "cursor", Code: "invalid",
+				Detail: "is not a valid cursor"})
+		} else if cur.Sort != sortParam || cur.Filter != req.filter {
+			errs = append(errs, apperr.FieldError{Parameter: "cursor", Code: "mismatch",
+				Detail: "was issued for a different sort order or filter"})
+		} else {
+			req.After = &cur
+		}
+	}
+
+	if len(errs) > 0 {
+		return req, apperr.Invalid(errs)
+	}
+	return req, nil
+}
+
+// SortKey is the sort parameter the request was parsed from.
+func (req Request) SortKey() string {
+	if req.Desc {
+		return "-" + req.Sort
+	}
+	return req.Sort
+}
+
+// Field describes a sortable column of rows of type T.
+type Field[T any] struct {
+	Column string         // SQL expression sorted on
+	Cast   string         // Postgres type of the column, for the cursor value
+	Value  func(T) string // the row's value of Column, as text
+	// Compare orders the row's value against one produced by Value, for
+	// stores that sort in Go rather than in SQL.
+	Compare func(row T, value string) int
+}
+
+// After reports whether row comes after the cursor in the requested order,
+// the Go counterpart of the condition Keyset adds.
+func After[T any](row T, id int, req Request, f Field[T]) bool {
+	if req.After == nil {
+		return true
+	}
+	c := f.Compare(row, req.After.Value)
+	if c == 0 {
+		c = cmpInt(id, req.After.ID)
+	}
+	if req.Desc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// Less orders two rows the way Keyset's ORDER BY does.
+func Less[T any](a T, aID int, b T, bID int, req Request, f Field[T]) bool {
+	c := f.Compare(a, f.Value(b))
+	if c == 0 {
+		c = cmpInt(aID, bID)
+	}
+	if req.Desc {
+		return c > 0
+	}
+	return c < 0
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Builder accumulates WHERE conditions and their positional arguments.
+type Builder struct {
+	conds []string
+	args  []any
+}
+
+// Arg records v and returns its placeholder.
+func (b *Builder) Arg(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+// Where adds a condition ANDed with the others.
+func (b *Builder) Where(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+// Args returns the arguments in placeholder order.
+func (b *Builder) Args() []any { return b.args }
+
+// Clause renders the WHERE clause, or "" without conditions.
+func (b *Builder) Clause() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.conds, " AND ")
+}
+
+// Keyset adds the condition that skips rows up to the cursor and returns
+// the ORDER BY and LIMIT clauses. One row more than the limit is fetched to
+// learn whether another page follows.
+func Keyset[T any](b *Builder, req Request, f Field[T]) string {
+	dir, op := "ASC", ">"
+	if req.Desc {
+		dir, op = "DESC", "<"
+	}
+	if req.After != nil {
+		b.Where(fmt.Sprintf("(%s, id) %s (%s::%s, %s)",
+			f.Column, op, b.Arg(req.After.Value), f.Cast, b.Arg(req.After.ID)))
+	}
+	return fmt.Sprintf("ORDER BY %s %s, id %s LIMIT %d", f.Column, dir, dir, req.Limit+1)
+}
+
+// Page is the envelope of every list response.
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage trims the extra row Keyset fetched and, when there was one,
+// builds the cursor of the following page.
+func NewPage[T any](rows []T, req Request, f Field[T], id func(T) int, codec *Codec) Page[T] {
+	if rows == nil {
+		rows = []T{}
+	}
+	if len(rows) <= req.Limit {
+		return Page[T]{Data: rows}
+	}
+	rows = rows[:req.Limit]
+	last := rows[len(rows)-1]
+	next := codec.Encode(Cursor{
+		Sort:   req.SortKey(),
+		Value:  f.Value(last),
+		ID:     id(last),
+		Filter: req.filter,
+	})
+	return Page[T]{Data: rows, NextCursor: next}
+}
+
+// SetLink adds an RFC 8288 Link header pointing at the next page.
+func SetLink(w http.ResponseWriter, r *http.Request, next string) {
+	if next == "" {
+		return
+	}
+	q := r.URL.Query()
+	q.Set("cursor", next)
+	u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, u.String()))
+}
+
+// digest fingerprints the filter parameters and the scope so a cursor only
+// continues the query it came from.
+func digest(q url.Values, filters, scope []string) string {
+	names := append([]string(nil), filters...)
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%s&", url.QueryEscape(name), url.QueryEscape(q.Get(name)))
+	}
+	for _, v := range scope {
+		fmt.Fprintf(h, "|%s", url.QueryEscape(v))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package paging
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestCodecFromSecret(t *testing.T) {
+	if _, err := CodecFromSecret("", false); !errors.Is(err, ErrNoSecret) {
+		t.Errorf("err = %v, want ErrNoSecret", err)
+	}
+	if c, err := CodecFromSecret("", true); err != nil || c == nil {
+		t.Errorf("ephemeral: codec = %v, err = %v", c, err)
+	}
+
+	a, _ := CodecFromSecret("shared secret", false)
+	b, _ := CodecFromSecret("shared secret", false)
+	cur := Cursor{Sort: "name", Value: "lamp", ID: 3}
+	if got, ok := b.Decode(a.Encode(cur)); !ok || got != cur {
+		t.Errorf("replicas sharing a secret disagree: %+v, %v", got, ok)
+	}
+}
+
+func TestDigestScope(t *testing.T) {
+	q := url.Values{"q": {"lamp"}}
+	if digest(q, []string{"q"}, []string{"owner=1"}) == digest(q, []string{"q"}, []string{"owner=2"}) {
+		t.Error("digest ignores the scope")
+	}
+	if digest(q, []string{"q"}, nil) != digest(q, []string{"q"}, nil) {
+		t.Error("digest is not stable")
+	}
+}
@@ -0,0 +1,136 @@
+// Package password hashes and verifies user passwords.
+//
+// New hashes use argon2id encoded in the PHC string format, so the algorithm
+// and its parameters travel with every hash:
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+//
+// bcrypt hashes ($2a$, $2b$, $2y$) from older rows are still accepted and are
+// reported as needing a rehash, as are argon2id hashes made with parameters
+// weaker than the current ones.
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Params are the argon2id cost parameters.
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultParams follows the OWASP recommendation for argon2id.
+var DefaultParams = Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+var ErrUnknownFormat = errors.New("password: unrecognised hash format")
+
+var b64 = base64.RawStdEncoding
+
+// Hash returns the argon2id hash of plain using DefaultParams.
+func Hash(plain string) (string, error) {
+	return HashWithParams(plain, DefaultParams)
+}
+
+// HashWithParams returns the argon2id hash of plain using p.
+func HashWithParams(plain string, p Params) (string, error) {
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("password: failed to read salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify reports whether plain matches encoded, and whether encoded should
+// be replaced by a fresh Hash because it uses an outdated algorithm or
+// weaker parameters. The comparison runs in constant time.
+func Verify(plain, encoded string) (ok bool, needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(encoded, "$argon2id$"):
+		return verifyArgon2id(plain, encoded)
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, fmt.Errorf("password: %w", err)
+		}
+		return true, true, nil
+	default:
+		return false, false, ErrUnknownFormat
+	}
+}
+
+// IsHashed reports whether s looks like a hash this package can verify, as
+// opposed to a legacy plaintext password.
+func IsHashed(s string) bool {
+	return strings.HasPrefix(s, "$argon2id$") || isBcrypt(s)
+}
+
+func isBcrypt(s string) bool {
+	return strings.HasPrefix(s, "$2a$") || strings.HasPrefix(s, "$2b$") ||
+		strings.HasPrefix(s, "$2y$")
+}
+
+func verifyArgon2id(plain, encoded string) (bool, bool, error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return false, false, ErrUnknownFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, ErrUnknownFormat
+	}
+	if version != argon2.Version {
+		return false, false, fmt.Errorf("password: unsupported argon2 version %d", version)
+	}
+	var p Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d",
+		&p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return false, false, ErrUnknownFormat
+	}
+	salt, err := b64.DecodeString(parts[4])
+	if err != nil {
+		return false, false, ErrUnknownFormat
+	}
+	key, err := b64.DecodeString(parts[5])
+	if err != nil {
+		return false, false, ErrUnknownFormat
+	}
+	p.SaltLength = uint32(len(salt))
+	p.KeyLength = uint32(len(key))
+
+	other := argon2.IDKey([]byte(plain), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return false, false, nil
+	}
+	return true, weaker(p, DefaultParams), nil
+}
+
+func weaker(p, target Params) bool {
+	return p.Memory < target.Memory || p.Iterations < target.Iterations ||
+		p.Parallelism < target.Parallelism || p.SaltLength < target.SaltLength ||
+		p.KeyLength < target.KeyLength
+}
@@ -0,0 +1,518 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dberr"
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/paging"
+)
+
+// NewMemory returns empty in-memory stores sharing one dataset, so that
+// product owners must exist and deleting a user removes their products as
+// the foreign key does in Postgres. Text is compared bytewise where
+// Postgres would use the database collation, and Search approximates the
+// full-text engine with plain word matching. Like a query, a call fails with
+// the context's error once ctx is done.
+func NewMemory() Repositories {
+	m := &memory{
+		users:    map[int]models.User{},
+		roles:    map[int][]string{},
+		products: map[int]models.Product{},
+		now:      func() time.Time { return time.Now().UTC().Truncate(time.Microsecond) },
+	}
+	return Repositories{Users: &memUsers{m}, Products: &memProducts{m}, Roles: &memRoles{m}}
+}
+
+type memory struct {
+	mu          sync.RWMutex
+	users       map[int]models.User
+	roles       map[int][]string
+	products    map[int]models.Product
+	lastUser    int
+	lastProduct int
+	now         func() time.Time
+}
+
+// lock and rlock take the lock unless ctx is already done, in which case
+// they fail the way a query on a cancelled context does.
+func (m *memory) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	return nil
+}
+
+func (m *memory) rlock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.RLock()
+	return nil
+}
+
+type memUsers struct{ *memory }
+
+func (s *memUsers) Create(ctx context.Context, u *models.User, role string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if err := s.checkUnique(0, u.Email, u.Username); err != nil {
+		return err
+	}
+	s.lastUser++
+	u.ID = s.lastUser
+	u.CreatedAt = s.now()
+	u.UpdatedAt = u.CreatedAt
+	s.users[u.ID] = *u
+	s.roles[u.ID] = []string{role}
+	return nil
+}
+
+// checkUnique mirrors the lower(email) and lower(username) unique indexes.
+func (s *memUsers) checkUnique(id int, email, username string) error {
+	for _, other := range s.users {
+		if other.ID == id {
+			continue
+		}
+		if strings.EqualFold(other.Email, email) {
+			return dberr.Taken("email", nil)
+		}
+		if strings.EqualFold(other.Username, username) {
+			return dberr.Taken("username", nil)
+		}
+	}
+	return nil
+}
+
+func (s *memUsers) List(ctx context.Context, q UserQuery) ([]models.User, error) {
+	if err := s.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
+
+	field := UserSorts[q.Page.Sort]
+	users := []models.User{}
+	for _, u := range s.users {
+		if q.CreatedAfter != nil && u.CreatedAt.Before(*q.CreatedAfter) {
+			continue
+		}
+		if q.CreatedBefore != nil && !u.CreatedAt.Before(*q.CreatedBefore) {
+			continue
+		}
+		if !paging.After(u, u.ID, q.Page, field) {
+			continue
+		}
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return paging.Less(users[i], users[i].ID, users[j], users[j].ID, q.Page, field)
+	})
+	if len(users) > q.Page.Limit+1 {
+		users = users[:q.Page.Limit+1]
+	}
+	return users, nil
+}
+
+func (s *memUsers) Get(ctx context.Context, id int) (models.User, error) {
+	if err := s.rlock(ctx); err != nil {
+		return models.User{}, err
+	}
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (s *memUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	if err := s.rlock(ctx); err != nil {
+		return models.User{}, err
+	}
+	defer s.mu.RUnlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (s *memUsers) Update(ctx context.Context, id int, patch UserPatch) (models.User, error) {
+	if err := s.lock(ctx); err != nil {
+		return models.User{}, err
+	}
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	before := u
+	set(&u.Username, patch.Username)
+	set(&u.Email, patch.Email)
+	set(&u.Password, patch.PasswordHash)
+	set(&u.FirstName, patch.FirstName)
+	set(&u.LastName, patch.LastName)
+	set(&u.Picture, patch.Picture)
+	if err := s.checkUnique(id, u.Email, u.Username); err != nil {
+		return models.User{}, err
+	}
+	if u.Username != before.Username || u.Email != before.Email ||
+		u.Password != before.Password || u.FirstName != before.FirstName ||
+		u.LastName != before.LastName || u.Picture != before.Picture {
+		u.UpdatedAt = s.now()
+	}
+	s.users[id] = u
+	return u, nil
+}
+
+func (s *memUsers) SetPassword(ctx context.Context, id int, hash string) error {
+	_, err := s.Update(ctx, id, UserPatch{PasswordHash: &hash})
+	return err
+}
+
+func (s *memUsers) Delete(ctx context.Context, id int) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.users, id)
+	delete(s.roles, id)
+	for pid, p := range s.products {
+		if p.UserID == id {
+			delete(s.products, pid)
+		}
+	}
+	return nil
+}
+
+type memRoles struct{ *memory }
+
+func (s *memRoles) UserRoles(ctx context.Context, userID int) ([]string, error) {
+	if err := s.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
+	roles := append([]string{}, s.roles[userID]...)
+	sort.Strings(roles)
+	return roles, nil
+}
+
+func (s *memRoles) Grant(ctx context.Context, userID int, role string) error {
+	if _, ok := auth.Catalogue[role]; !ok {
+		return auth.ErrUnknownRole
+	}
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return auth.ErrUnknownUser
+	}
+	for _, held := range s.roles[userID] {
+		if held == role {
+			return nil
+		}
+	}
+	s.roles[userID] = append(s.roles[userID], role)
+	return nil
+}
+
+func (s *memRoles) Revoke(ctx context.Context, userID int, role string) (bool, error) {
+	if _, ok := auth.Catalogue[role]; !ok {
+		return false, auth.ErrUnknownRole
+	}
+	if err := s.lock(ctx); err != nil {
+		return false, err
+	}
+	defer s.mu.Unlock()
+	roles := s.roles[userID]
+	for i, held := range roles {
+		if held == role {
+			s.roles[userID] = append(roles[:i:i], roles[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type memProducts struct{ *memory }
+
+func (s *memProducts) Create(ctx context.Context, p *models.Product) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+	if _, ok := s.users[p.UserID]; !ok {
+		return dberr.MissingReference("user_id", nil)
+	}
+	s.lastProduct++
+	p.ID = s.lastProduct
+	p.CreatedAt = s.now()
+	p.UpdatedAt = p.CreatedAt
+	p.DeletedAt = nil
+	s.products[p.ID] = *p
+	return nil
+}
+
+func (s *memProducts) List(ctx context.Context, q ProductQuery) ([]models.Product, error) {
+	if err := s.rlock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.RUnlock()
+
+	field := ProductSorts[q.Page.Sort]
+	products := []models.Product{}
+	for _, p := range s.products {
+		switch {
+		case !q.IncludeDeleted && p.DeletedAt != nil,
+			q.MinPrice != nil && p.Price.Cmp(*q.MinPrice) < 0,
+			q.MaxPrice != nil && p.Price.Cmp(*q.MaxPrice) > 0,
+			q.InStock != nil && *q.InStock != (p.Quantity > 0),
+			q.UserID != nil && p.UserID != *q.UserID,
+			q.CreatedAfter != nil && p.CreatedAt.Before(*q.CreatedAfter),
+			q.CreatedBefore != nil && !p.CreatedAt.Before(*q.CreatedBefore),
+			!paging.After(p, p.ID, q.Page, field):
+			continue
+		}
+		products = append(products, copyProduct(p))
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return paging.Less(products[i], products[i].ID, products[j], products[j].ID, q.Page, field)
+	})
+	if len(products) > q.Page.Limit+1 {
+		products = products[:q.Page.Limit+1]
+	}
+	return products, nil
+}
+
+func (s *memProducts) Get(ctx context.Context, id int, includeDeleted bool) (models.Product, error) {
+	if err := s.rlock(ctx); err != nil {
+		return models.Product{}, err
+	}
+	defer s.mu.RUnlock()
+	p, ok := s.products[id]
+	if !ok || (p.DeletedAt != nil && !includeDeleted) {
+		return models.Product{}, ErrNotFound
+	}
+	return copyProduct(p), nil
+}
+
+func (s *memProducts) Update(ctx context.Context, id int, patch ProductPatch, owner Owner) (models.Product, error) {
+	if err := s.lock(ctx); err != nil {
+		return models.Product{}, err
+	}
+	defer s.mu.Unlock()
+	p, ok := s.products[id]
+	if !ok || p.DeletedAt != nil {
+		return models.Product{}, ErrNotFound
+	}
+	if !owner.allows(p.UserID) {
+		return models.Product{}, ErrNotOwner
+	}
+	before := p
+	set(&p.Name, patch.Name)
+	set(&p.Description, patch.Description)
+	set(&p.Price, patch.Price)
+	set(&p.Currency, patch.Currency)
+	set(&p.Quantity, patch.Quantity)
+	if p != before {
+		p.UpdatedAt = s.now()
+	}
+	s.products[id] = p
+	return copyProduct(p), nil
+}
+
+func (s *memProducts) SetDeleted(ctx context.Context, id int, deleted bool, owner Owner) (models.Product, error) {
+	if err := s.lock(ctx); err != nil {
+		return models.Product{}, err
+	}
+	defer s.mu.Unlock()
+	p, ok := s.products[id]
+	if !ok || (p.DeletedAt == nil) != deleted {
+		return models.Product{}, ErrNotFound
+	}
+	if !owner.allows(p.UserID) {
+		return models.Product{}, ErrNotOwner
+	}
+	p.UpdatedAt = s.now()
+	p.DeletedAt = nil
+	if deleted {
+		now := p.UpdatedAt
+		p.DeletedAt = &now
+	}
+	s.products[id] = p
+	return copyProduct(p), nil
+}
+
+// Search treats the query as words that must all occur in the name or
+// description, ignoring quotes, OR and excluded -words. Without a match
+// it returns products whose name shares enough trigrams with the query.
+func (s *memProducts) Search(ctx context.Context, query string, limit int) (SearchResult, error) {
+	if err := s.rlock(ctx); err != nil {
+		return SearchResult{}, err
+	}
+	defer s.mu.RUnlock()
+
+	terms := searchTerms(query)
+	var hits []SearchHit
+	for _, p := range s.products {
+		if p.DeletedAt != nil || len(terms) == 0 {
+			continue
+		}
+		rank, all := 0.0, true
+		for _, t := range terms {
+			inName := containsFold(p.Name, t)
+			inDesc := containsFold(p.Description, t)
+			switch {
+			case inName:
+				rank += 1
+			case inDesc:
+				rank += 0.4
+			default:
+				all = false
+			}
+		}
+		if all {
+			hits = append(hits, SearchHit{Product: copyProduct(p), Rank: rank / float64(len(terms)),
+				NameHighlight: mark(p.Name, terms), DescriptionHighlight: mark(p.Description, terms)})
+		}
+	}
+	fuzzy := false
+	if len(hits) == 0 {
+		fuzzy = true
+		for _, p := range s.products {
+			if p.DeletedAt != nil {
+				continue
+			}
+			if sim := wordSimilarity(query, p.Name); sim >= 0.6 {
+				hits = append(hits, SearchHit{Product: copyProduct(p), Rank: sim,
+					NameHighlight: p.Name, DescriptionHighlight: p.Description})
+			}
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Rank != hits[j].Rank {
+			return hits[i].Rank > hits[j].Rank
+		}
+		return hits[i].Product.ID < hits[j].Product.ID
+	})
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	if hits == nil {
+		hits = []SearchHit{}
+	}
+	return SearchResult{Hits: hits, Fuzzy: fuzzy}, nil
+}
+
+func set[T any](dst *T, v *T) {
+	if v != nil {
+		*dst = *v
+	}
+}
+
+func copyProduct(p models.Product) models.Product {
+	if p.DeletedAt != nil {
+		at := *p.DeletedAt
+		p.DeletedAt = &at
+	}
+	return p
+}
+
+func searchTerms(query string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ReplaceAll(query, `"`, " ")) {
+		if strings.HasPrefix(word, "-") || strings.EqualFold(word, "or") {
+			continue
+		}
+		terms = append(terms, strings.ToLower(word))
+	}
+	return terms
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), substr)
+}
+
+// mark wraps every occurrence of the terms in s with the highlight markers.
+func mark(s string, terms []string) string {
+	lower := strings.ToLower(s)
+	if len(lower) != len(s) {
+		return s // case folding changed byte offsets; leave unmarked
+	}
+	marked := make([]bool, len(s))
+	for _, t := range terms {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], t)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(t); k++ {
+				marked[k] = true
+			}
+			i += j + len(t)
+		}
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(HighlightStart)
+		}
+		b.WriteByte(s[i])
+		if marked[i] && (i == len(s)-1 || !marked[i+1]) {
+			b.WriteString(HighlightStop)
+		}
+	}
+	return b.String()
+}
+
+// wordSimilarity approximates pg_trgm's word_similarity: the best trigram
+// similarity between the query and any single word of s.
+func wordSimilarity(query, s string) float64 {
+	q := trigrams(query)
+	best := 0.0
+	for _, word := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		w := trigrams(word)
+		shared := 0
+		for t := range q {
+			if w[t] {
+				shared++
+			}
+		}
+		if union := len(q) + len(w) - shared; union > 0 {
+			if sim := float64(shared) / float64(union); sim > best {
+				best = sim
+			}
+		}
+	}
+	return best
+}
+
+func trigrams(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,401 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+	"github.com/mdarify1337/backend-go/backend/dberr"
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/paging"
+)
+
+const userColumns = `id, username, email, password, first_name, last_name,
+	created_at, updated_at, picture`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password,
+		&u.FirstName, &u.LastName, &u.CreatedAt, &u.UpdatedAt, &u.Picture)
+	return u, err
+}
+
+type pgUsers struct {
+	db       *sql.DB
+	timeouts dbctx.Timeouts
+}
+
+func (s *pgUsers) Create(ctx context.Context, u *models.User, role string) (err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "users.create")
+	defer done(&err)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("db transaction error: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (username, email, password, first_name,
+		last_name, picture)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at;
+	`, u.Username, u.Email, u.Password, u.FirstName, u.LastName, u.Picture,
+	).Scan(&u.ID, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return dberr.Translate(fmt.Errorf("db insert error: %w", err))
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO user_roles (user_id, role) VALUES ($1, $2);`, u.ID, role); err != nil {
+		return fmt.Errorf("db insert error: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("db commit error: %w", err)
+	}
+	return nil
+}
+
+func (s *pgUsers) List(ctx context.Context, q UserQuery) (_ []models.User, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, "users.list")
+	defer done(&err)
+
+	var b paging.Builder
+	if q.CreatedAfter != nil {
+		b.Where("created_at >= " + b.Arg(*q.CreatedAfter))
+	}
+	if q.CreatedBefore != nil {
+		b.Where("created_at < " + b.Arg(*q.CreatedBefore))
+	}
+	order := paging.Keyset(&b, q.Page, UserSorts[q.Page.Sort])
+
+	query := fmt.Sprintf(`SELECT %s FROM users %s %s;`, userColumns, b.Clause(), order)
+	rows, err := s.db.QueryContext(ctx, query, b.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("db query error: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return users, nil
+}
+
+func (s *pgUsers) Get(ctx context.Context, id int) (models.User, error) {
+	return s.getOne(ctx, "users.get", `SELECT `+userColumns+` FROM users WHERE id=$1;`, id)
+}
+
+func (s *pgUsers) GetByEmail(ctx context.Context, email string) (models.User, error) {
+	return s.getOne(ctx, "users.get_by_email",
+		`SELECT `+userColumns+` FROM users WHERE lower(email)=lower($1);`, email)
+}
+
+func (s *pgUsers) getOne(ctx context.Context, op, query string, arg any) (_ models.User, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, op)
+	defer done(&err)
+
+	u, err := scanUser(s.db.QueryRowContext(ctx, query, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	} else if err != nil {
+		return u, fmt.Errorf("db query error: %w", err)
+	}
+	return u, nil
+}
+
+func (s *pgUsers) Update(ctx context.Context, id int, patch UserPatch) (_ models.User, err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "users.update")
+	defer done(&err)
+
+	// NULL parameters leave the column untouched
+	u, err := scanUser(s.db.QueryRowContext(ctx, `
+		UPDATE users
+		SET username=COALESCE($1, username), email=COALESCE($2, email),
+		    password=COALESCE($3, password),
+		    first_name=COALESCE($4, first_name), last_name=COALESCE($5, last_name),
+		    picture=COALESCE($6, picture)
+		WHERE id=$7
+		RETURNING `+userColumns+`;
+	`, patch.Username, patch.Email, patch.PasswordHash, patch.FirstName,
+		patch.LastName, patch.Picture, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return u, ErrNotFound
+	} else if err != nil {
+		return u, dberr.Translate(fmt.Errorf("db update error: %w", err))
+	}
+	return u, nil
+}
+
+func (s *pgUsers) SetPassword(ctx context.Context, id int, hash string) (err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "users.set_password")
+	defer done(&err)
+
+	result, err := s.db.ExecContext(ctx, `UPDATE users SET password=$1 WHERE id=$2;`, hash, id)
+	if err != nil {
+		return fmt.Errorf("db update error: %w", err)
+	}
+	return requireRow(result)
+}
+
+func (s *pgUsers) Delete(ctx context.Context, id int) (err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "users.delete")
+	defer done(&err)
+
+	result, err := s.db.ExecContext(ctx, `DELETE FROM users WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return requireRow(result)
+}
+
+func requireRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve affected rows: %w", err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+const productColumns = `id, name, description, price, currency, quantity,
+	created_at, updated_at, user_id, deleted_at`
+
+func scanProduct(row rowScanner, extra ...any) (models.Product, error) {
+	var p models.Product
+	dest := append([]any{&p.ID, &p.Name, &p.Description, &p.Price, &p.Currency,
+		&p.Quantity, &p.CreatedAt, &p.UpdatedAt, &p.UserID, &p.DeletedAt}, extra...)
+	err := row.Scan(dest...)
+	return p, err
+}
+
+type pgProducts struct {
+	db       *sql.DB
+	timeouts dbctx.Timeouts
+}
+
+func (s *pgProducts) Create(ctx context.Context, p *models.Product) (err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "products.create")
+	defer done(&err)
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO products (name, description, price, currency, quantity, user_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at;
+	`, p.Name, p.Description, p.Price, p.Currency, p.Quantity, p.UserID,
+	).Scan(&p.ID, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return dberr.Translate(fmt.Errorf("db insert error: %w", err))
+	}
+	return nil
+}
+
+func (s *pgProducts) List(ctx context.Context, q ProductQuery) ([]models.Product, error) {
+	var b paging.Builder
+	if !q.IncludeDeleted {
+		b.Where("deleted_at IS NULL")
+	}
+	if q.MinPrice != nil {
+		b.Where("price >= " + b.Arg(*q.MinPrice))
+	}
+	if q.MaxPrice != nil {
+		b.Where("price <= " + b.Arg(*q.MaxPrice))
+	}
+	if q.InStock != nil {
+		if *q.InStock {
+			b.Where("quantity > 0")
+		} else {
+			b.Where("quantity <= 0")
+		}
+	}
+	if q.UserID != nil {
+		b.Where("user_id = " + b.Arg(*q.UserID))
+	}
+	if q.CreatedAfter != nil {
+		b.Where("created_at >= " + b.Arg(*q.CreatedAfter))
+	}
+	if q.CreatedBefore != nil {
+		b.Where("created_at < " + b.Arg(*q.CreatedBefore))
+	}
+	order := paging.Keyset(&b, q.Page, ProductSorts[q.Page.Sort])
+
+	query := fmt.Sprintf(`SELECT %s FROM products %s %s;`, productColumns, b.Clause(), order)
+	return s.query(ctx, "products.list", query, b.Args()...)
+}
+
+func (s *pgProducts) query(ctx context.Context, op, query string, args ...any) (_ []models.Product, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, op)
+	defer done(&err)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db query error: %w", err)
+	}
+	defer rows.Close()
+
+	products := []models.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+		products = append(products, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+	return products, nil
+}
+
+func (s *pgProducts) Get(ctx context.Context, id int, includeDeleted bool) (_ models.Product, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, "products.get")
+	defer done(&err)
+
+	p, err := scanProduct(s.db.QueryRowContext(ctx, `
+		SELECT `+productColumns+`
+		FROM products
+		WHERE id = $1 AND (deleted_at IS NULL OR $2);
+	`, id, includeDeleted))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, ErrNotFound
+	} else if err != nil {
+		return p, fmt.Errorf("db query error: %w", err)
+	}
+	return p, nil
+}
+
+func (s *pgProducts) Update(ctx context.Context, id int, patch ProductPatch, owner Owner) (_ models.Product, err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "products.update")
+	defer done(&err)
+
+	// Ownership is checked in the same statement so it cannot race with a
+	// concurrent delete
+	p, err := scanProduct(s.db.QueryRowContext(ctx, `
+		UPDATE products
+		SET name=COALESCE($1, name), description=COALESCE($2, description),
+			price=COALESCE($3, price), currency=COALESCE($4, currency),
+			quantity=COALESCE($5, quantity)
+		WHERE id=$6 AND deleted_at IS NULL AND (user_id=$7 OR $8)
+		RETURNING `+productColumns+`;
+	`, patch.Name, patch.Description, patch.Price, patch.Currency, patch.Quantity,
+		id, owner.UserID, owner.Any))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, s.denied(ctx, id, false)
+	} else if err != nil {
+		return p, dberr.Translate(fmt.Errorf("db update error: %w", err))
+	}
+	return p, nil
+}
+
+func (s *pgProducts) SetDeleted(ctx context.Context, id int, deleted bool, owner Owner) (_ models.Product, err error) {
+	ctx, done := s.timeouts.ForWrite(ctx, "products.set_deleted")
+	defer done(&err)
+
+	p, err := scanProduct(s.db.QueryRowContext(ctx, `
+		UPDATE products
+		SET deleted_at = CASE WHEN $1 THEN now() END
+		WHERE id=$2 AND (deleted_at IS NULL) = $1 AND (user_id=$3 OR $4)
+		RETURNING `+productColumns+`;
+	`, deleted, id, owner.UserID, owner.Any))
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, s.denied(ctx, id, !deleted)
+	} else if err != nil {
+		return p, fmt.Errorf("db update error: %w", err)
+	}
+	return p, nil
+}
+
+// denied explains why a guarded write matched no row: either the product
+// does not exist in the expected deleted state, or it belongs to someone
+// else.
+func (s *pgProducts) denied(ctx context.Context, id int, deleted bool) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM products WHERE id=$1 AND (deleted_at IS NOT NULL) = $2);
+	`, id, deleted).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("db query error: %w", err)
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return ErrNotOwner
+}
+
+var headlineOptions = fmt.Sprintf(
+	"StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=\" … \"",
+	HighlightStart, HighlightStop)
+
+// fullTextSearch matches the websearch syntax (quoted phrases, OR, -word)
+// against the weighted search vector kept up to date by trigger.
+const fullTextSearch = `
+	SELECT p.id, p.name, p.description, p.price, p.currency, p.quantity,
+		p.created_at, p.updated_at, p.user_id, p.deleted_at,
+		ts_rank_cd(p.search, q) AS rank,
+		ts_headline('english', p.name, q, $3) AS name_headline,
+		ts_headline('english', coalesce(p.description, ''), q, $3) AS description_headline
+	FROM products p, websearch_to_tsquery('english', $1) q
+	WHERE p.deleted_at IS NULL AND p.search @@ q
+	ORDER BY rank DESC, p.id
+	LIMIT $2;
+`
+
+// fuzzySearch tolerates typos by comparing trigrams of the query with the
+// words of product names.
+const fuzzySearch = `
+	SELECT p.id, p.name, p.description, p.price, p.currency, p.quantity,
+		p.created_at, p.updated_at, p.user_id, p.deleted_at,
+		word_similarity($1, p.name) AS rank,
+		p.name, coalesce(p.description, '')
+	FROM products p
+	WHERE p.deleted_at IS NULL AND $1 <% p.name
+	ORDER BY rank DESC, p.id
+	LIMIT $2;
+`
+
+func (s *pgProducts) Search(ctx context.Context, query string, limit int) (_ SearchResult, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, "products.search")
+	defer done(&err)
+
+	hits, err := s.search(ctx, fullTextSearch, query, limit, headlineOptions)
+	if err != nil || len(hits) > 0 {
+		return SearchResult{Hits: hits}, err
+	}
+	hits, err = s.search(ctx, fuzzySearch, query, limit)
+	return SearchResult{Hits: hits, Fuzzy: true}, err
+}
+
+func (s *pgProducts) search(ctx context.Context, query string, args ...any) ([]SearchHit, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("db search error: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		hit.Product, err = scanProduct(rows, &hit.Rank, &hit.NameHighlight, &hit.DescriptionHighlight)
+		if err != nil {
+			return nil, fmt.Errorf("row scan error: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
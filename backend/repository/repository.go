@@ -0,0 +1,195 @@
+// Package repository is the data access layer behind the controllers.
+//
+// Every store comes in two implementations with the same semantics: the
+// Postgres one used in production, and an in-memory one that lets handlers
+// run under httptest without a database. Both report missing rows as
+// ErrNotFound, writes to someone else's product as ErrNotOwner and
+// uniqueness or reference violations as the *apperr.Error values built by
+// package dberr.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/money"
+	"github.com/mdarify1337/backend-go/backend/paging"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+	ErrNotOwner = errors.New("record belongs to another user")
+)
+
+// Repositories bundles the stores the API is built on.
+type Repositories struct {
+	Users    UserRepository
+	Products ProductRepository
+	Roles    auth.RoleRepository
+}
+
+// NewPostgres returns the stores backed by db, each operation bounded by
+// timeouts.
+func NewPostgres(db *sql.DB, timeouts dbctx.Timeouts) Repositories {
+	return Repositories{
+		Users:    &pgUsers{db: db, timeouts: timeouts},
+		Products: &pgProducts{db: db, timeouts: timeouts},
+		Roles:    auth.NewRoleStore(db, timeouts),
+	}
+}
+
+// UserRepository stores user accounts.
+type UserRepository interface {
+	// Create inserts u with its initial role and fills in the ID and
+	// timestamps.
+	Create(ctx context.Context, u *models.User, role string) error
+	// List returns up to q.Page.Limit+1 users, the extra one telling the
+	// caller that another page follows.
+	List(ctx context.Context, q UserQuery) ([]models.User, error)
+	Get(ctx context.Context, id int) (models.User, error)
+	// GetByEmail looks a user up by email, ignoring case.
+	GetByEmail(ctx context.Context, email string) (models.User, error)
+	Update(ctx context.Context, id int, patch UserPatch) (models.User, error)
+	SetPassword(ctx context.Context, id int, hash string) error
+	// Delete removes the user together with their products.
+	Delete(ctx context.Context, id int) error
+}
+
+// UserQuery filters and pages a user listing.
+type UserQuery struct {
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Page          paging.Request
+}
+
+// UserPatch changes the non-nil fields of a user. PasswordHash must already
+// be hashed.
+type UserPatch struct {
+	Username     *string
+	Email        *string
+	PasswordHash *string
+	FirstName    *string
+	LastName     *string
+	Picture      *string
+}
+
+// ProductRepository stores products. Deleted products are soft-deleted and
+// hidden unless asked for.
+type ProductRepository interface {
+	// Create inserts p and fills in the ID and timestamps.
+	Create(ctx context.Context, p *models.Product) error
+	// List returns up to q.Page.Limit+1 products, like UserRepository.List.
+	List(ctx context.Context, q ProductQuery) ([]models.Product, error)
+	Get(ctx context.Context, id int, includeDeleted bool) (models.Product, error)
+	// Update changes a live product that owner may write.
+	Update(ctx context.Context, id int, patch ProductPatch, owner Owner) (models.Product, error)
+	// SetDeleted soft-deletes a live product or restores a deleted one.
+	SetDeleted(ctx context.Context, id int, deleted bool, owner Owner) (models.Product, error)
+	// Search ranks live products against a web-style query, falling back
+	// to similar names when nothing matches.
+	Search(ctx context.Context, query string, limit int) (SearchResult, error)
+}
+
+// ProductQuery filters and pages a product listing.
+type ProductQuery struct {
+	MinPrice       *money.Amount
+	MaxPrice       *money.Amount
+	InStock        *bool
+	UserID         *int
+	CreatedAfter   *time.Time
+	CreatedBefore  *time.Time
+	IncludeDeleted bool
+	Page           paging.Request
+}
+
+// ProductPatch changes the non-nil fields of a product.
+type ProductPatch struct {
+	Name        *string
+	Description *string
+	Price       *money.Amount
+	Currency    *string
+	Quantity    *int
+}
+
+// Owner is who a product write is made for: the owner UserID, or anyone's
+// products when Any is set.
+type Owner struct {
+	UserID int
+	Any    bool
+}
+
+func (o Owner) allows(userID int) bool { return o.Any || o.UserID == userID }
+
+// Highlights mark matched terms with HighlightStart and HighlightStop; they
+// are control characters so callers can escape the snippet first and then
+// swap in real markup.
+const (
+	HighlightStart = "\x01"
+	HighlightStop  = "\x02"
+)
+
+// SearchResult lists search hits by relevance. Fuzzy is set when no product
+// matched the query and similar names were returned instead.
+type SearchResult struct {
+	Hits  []SearchHit
+	Fuzzy bool
+}
+
+// SearchHit is one product found by Search.
+type SearchHit struct {
+	Product              models.Product
+	Rank                 float64
+	NameHighlight        string
+	DescriptionHighlight string
+}
+
+// UserSorts are the fields users can be ordered by.
+var UserSorts = map[string]paging.Field[models.User]{
+	"created_at": {
+		Column:  "created_at",
+		Cast:    "timestamptz",
+		Value:   func(u models.User) string { return u.CreatedAt.Format(time.RFC3339Nano) },
+		Compare: func(u models.User, v string) int { return compareTime(u.CreatedAt, v) },
+	},
+	"username": {
+		Column:  "username",
+		Cast:    "text",
+		Value:   func(u models.User) string { return u.Username },
+		Compare: func(u models.User, v string) int { return strings.Compare(u.Username, v) },
+	},
+}
+
+// ProductSorts are the fields products can be ordered by.
+var ProductSorts = map[string]paging.Field[models.Product]{
+	"created_at": {
+		Column:  "created_at",
+		Cast:    "timestamptz",
+		Value:   func(p models.Product) string { return p.CreatedAt.Format(time.RFC3339Nano) },
+		Compare: func(p models.Product, v string) int { return compareTime(p.CreatedAt, v) },
+	},
+	"name": {
+		Column:  "name",
+		Cast:    "text",
+		Value:   func(p models.Product) string { return p.Name },
+		Compare: func(p models.Product, v string) int { return strings.Compare(p.Name, v) },
+	},
+	"price": {
+		Column: "price",
+		Cast:   "numeric",
+		Value:  func(p models.Product) string { return p.Price.String() },
+		Compare: func(p models.Product, v string) int {
+			return p.Price.Cmp(money.MustParse(v))
+		},
+	},
+}
+
+func compareTime(t time.Time, v string) int {
+	other, _ := time.Parse(time.RFC3339Nano, v)
+	return t.Compare(other)
+}
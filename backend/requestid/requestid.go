@@ -0,0 +1,51 @@
+// Package requestid tags every request with an ID that shows up in logs and
+// error responses, so a report from a client can be matched to the server
+// side.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// Header carries the request ID in both directions.
+const Header = "X-Request-ID"
+
+// valid limits IDs accepted from clients to something safe to log.
+var valid = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+type key struct{}
+
+// From returns the request ID stored in ctx, or "" when there is none.
+func From(ctx context.Context) string {
+	id, _ := ctx.Value(key{}).(string)
+	return id
+}
+
+// With returns a copy of ctx carrying id.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// New returns a random request ID.
+func New() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Middleware reuses a well-formed X-Request-ID from the client or makes a
+// new one, stores it in the request context and echoes it in the response.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid.MatchString(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(With(r.Context(), id)))
+	})
+}
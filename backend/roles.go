@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+)
+
+// runRoles grants or revokes roles from the command line, which is how the
+// first admin gets created.
+func runRoles(args []string) error {
+	if len(args) != 3 || (args[0] != "grant" && args[0] != "revoke") {
+		return errors.New("usage: roles grant|revoke <user_id> <role>")
+	}
+	userID, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("invalid user id %q", args[1])
+	}
+
+	cfg, err := loadConfig(nil, nil)
+	if err != nil {
+		return err
+	}
+	db, err := openDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	roles := auth.NewRoleStore(db, cfg.Database.Timeouts())
+	ctx := context.Background()
+	if args[0] == "grant" {
+		if err := roles.Grant(ctx, userID, args[2]); err != nil {
+			return err
+		}
+	} else if _, err := roles.Revoke(ctx, userID, args[2]); err != nil {
+		return err
+	}
+
+	held, err := roles.UserRoles(ctx, userID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("user %d roles: %v\n", userID, held)
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/controllers"
+	"github.com/mdarify1337/backend-go/backend/health"
+	"github.com/mdarify1337/backend-go/backend/httpx"
+	"github.com/mdarify1337/backend-go/backend/jobs"
+	"github.com/mdarify1337/backend-go/backend/logging"
+	"github.com/mdarify1337/backend-go/backend/metrics"
+	"github.com/mdarify1337/backend-go/backend/migrations"
+	"github.com/mdarify1337/backend-go/backend/paging"
+	"github.com/mdarify1337/backend-go/backend/repository"
+	"github.com/mdarify1337/backend-go/backend/requestid"
+	"github.com/mdarify1337/backend-go/backend/services"
+	"github.com/mdarify1337/backend-go/backend/tracing"
+)
+
+// enableCORS lets browsers on origin call the API and answers their
+// preflight requests. An empty origin disables CORS: no headers are sent and
+// OPTIONS requests reach the mux like any other.
+func enableCORS(origin string, next http.Handler) http.Handler {
+	if origin == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, traceparent, tracestate")
+		w.Header().Set("Access-Control-Expose-Headers", "Location, Link, Deprecation, X-Request-ID")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func runServe(args []string) error {
+	cfg, err := loadConfig(flag.NewFlagSet("serve", flag.ExitOnError), args)
+	if err != nil {
+		return err
+	}
+	slog.Info("configuration loaded", "config", cfg)
+	server := cfg.Server
+
+	db, err := openDB(cfg.Database)
+	if err != nil {
+		return err
+	}
+	// Deferred first so the pool outlives everything that queries it
+	defer func() {
+		db.Close()
+		slog.Info("database pool closed")
+	}()
+
+	// Missing secrets stop the server before it touches the database
+	timeouts := cfg.Database.Timeouts()
+	authCfg := cfg.Auth
+	authCfg.DB = timeouts
+	authCfg.Ephemeral = cfg.DevMode
+	tokens, err := auth.NewService(db, authCfg)
+	if err != nil {
+		return err
+	}
+
+	codec, err := paging.CodecFromSecret(cfg.Paging.CursorSecret, cfg.DevMode)
+	if err != nil {
+		return err
+	}
+	controllers.SetCursorCodec(codec)
+
+	if cfg.Database.Migrate {
+		if err := migrations.RunAll(db); err != nil {
+			return fmt.Errorf("[DB] Migration failed: %w", err)
+		}
+		slog.Info("migrations applied")
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	// Flushed after the server has drained, before the pool closes
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "err", err)
+		}
+	}()
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		jobs.RunProductPurge(jobsCtx, db, cfg.Database.Timeouts(), time.Duration(cfg.Jobs.PurgeAfterDays)*24*time.Hour, time.Hour)
+	}()
+	defer func() {
+		stopJobs()
+		<-jobsDone
+	}()
+
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ready := health.NewReadiness(server.ReadyTimeout, health.Database(db), health.Migrations(migrator))
+
+	metrics.RegisterDBStats(db, cfg.Database.Name)
+	metrics.RegisterMigrations(migrator, server.ReadyTimeout)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", health.Live)
+	mux.HandleFunc("GET /ping", health.Live)
+	mux.Handle("GET /readyz", ready)
+	services.RunAllServices(mux, repository.NewPostgres(db, timeouts), tokens)
+	srv := &http.Server{
+		Addr: server.Addr(),
+		Handler: requestid.Middleware(httpx.Resolve(mux, tracing.Middleware(logging.AccessLog(metrics.Instrument(
+			enableCORS(server.CORSOrigin, tokens.Issuer.Authenticate(services.WithProblems(mux)))))))),
+		ReadHeaderTimeout: server.ReadHeaderTimeout,
+		ReadTimeout:       server.ReadTimeout,
+		WriteTimeout:      server.WriteTimeout,
+		IdleTimeout:       server.IdleTimeout,
+	}
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- srv.ListenAndServe() }()
+	slog.Info("server listening", "addr", srv.Addr)
+
+	// Metrics stay off the public port; scrape them on the admin listener
+	adminErr := make(chan error, 1)
+	if server.AdminPort > 0 {
+		admin := http.NewServeMux()
+		admin.Handle("GET /metrics", metrics.Handler())
+		adminSrv := &http.Server{
+			Addr:              server.AdminAddr(),
+			Handler:           admin,
+			ReadHeaderTimeout: server.ReadHeaderTimeout,
+		}
+		go func() { adminErr <- adminSrv.ListenAndServe() }()
+		// Closed last, so the drain can still be scraped
+		defer adminSrv.Close()
+		slog.Info("admin server listening", "addr", adminSrv.Addr)
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case err := <-adminErr:
+		srv.Close()
+		return fmt.Errorf("admin server: %w", err)
+	case <-signals.Done():
+	}
+	// A second signal kills the process outright
+	stopSignals()
+
+	slog.Info("shutting down, failing readiness", "drain_delay", server.DrainDelay)
+	ready.Drain()
+	time.Sleep(server.DrainDelay)
+
+	slog.Info("draining in-flight requests", "grace", server.ShutdownGrace)
+	ctx, cancel := context.WithTimeout(context.Background(), server.ShutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("grace period over, closing remaining connections", "err", err)
+		srv.Close()
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	slog.Info("server stopped")
+	return nil
+}
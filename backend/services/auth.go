@@ -0,0 +1,48 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/controllers"
+	"github.com/mdarify1337/backend-go/backend/repository"
+)
+
+func AuthRoutes(mux *http.ServeMux, users repository.UserRepository, tokens auth.Sessions) {
+	mux.HandleFunc("POST /v1/auth/token",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			controllers.SignInUser(users, tokens, w, r)
+		}),
+	)
+	mux.HandleFunc("POST /v1/auth/refresh",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			controllers.RefreshToken(tokens, w, r)
+		}),
+	)
+	mux.HandleFunc("POST /v1/auth/logout",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			controllers.Logout(tokens, w, r)
+		}),
+	)
+
+	mux.HandleFunc("/RefreshToken",
+		deprecated("/v1/auth/refresh", auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			controllers.RefreshToken(tokens, w, r)
+		})),
+	)
+
+	mux.HandleFunc("/Logout",
+		deprecated("/v1/auth/logout", auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			controllers.Logout(tokens, w, r)
+		})),
+	)
+}
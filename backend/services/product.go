@@ -1,99 +1,171 @@
 package services
 
 import (
-	"database/sql"
-	"github.com/mdarify1337/backend-go/backend/controllers"
-	"log"
 	"net/http"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/controllers"
+	"github.com/mdarify1337/backend-go/backend/repository"
 )
 
-func ProductRoutes(mux *http.ServeMux, db *sql.DB) {
-	mux.HandleFunc("/CreateProduct",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /CreateProduct %s request\n", r.Method)
+func ProductRoutes(mux *http.ServeMux, products repository.ProductRepository) {
+	ctx := func(w http.ResponseWriter, r *http.Request) controllers.RequestContext {
+		return controllers.RequestContext{Products: products, W: w, R: r}
+	}
+
+	mux.HandleFunc("GET /v1/products",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			controllers.GetProducts(ctx(w, r))
+		}),
+	)
+	mux.HandleFunc("POST /v1/products",
+		auth.Require(auth.Permission(auth.PermProductsCreate), func(w http.ResponseWriter, r *http.Request) {
+			controllers.CreateProduct(ctx(w, r))
+		}),
+	)
+	mux.HandleFunc("GET /v1/products/search",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			controllers.SearchProducts(ctx(w, r))
+		}),
+	)
+	mux.HandleFunc("GET /v1/products/{id}",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.GetProductByID(ctx(w, r), id)
+			}
+		}),
+	)
+	mux.HandleFunc("PATCH /v1/products/{id}",
+		auth.Require(auth.Authenticated, func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.UpdateProduct(ctx(w, r), id)
+			}
+		}),
+	)
+	mux.HandleFunc("DELETE /v1/products/{id}",
+		auth.Require(auth.Authenticated, func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.DeleteProduct(ctx(w, r), id)
+			}
+		}),
+	)
+	mux.HandleFunc("POST /v1/products/{id}/restore",
+		auth.Require(auth.Authenticated, func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.RestoreProduct(ctx(w, r), id)
+			}
+		}),
+	)
+
+	legacyProductRoutes(mux, products)
+}
 
+// legacyProductRoutes keeps the RPC-style routes working for existing clients.
+func legacyProductRoutes(mux *http.ServeMux, products repository.ProductRepository) {
+	mux.HandleFunc("/CreateProduct",
+		deprecated("/v1/products", auth.Require(auth.Permission(auth.PermProductsCreate), func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodOptions {
-				log.Println("[API] Preflight request on /CreateProduct")
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 			if r.Method != http.MethodPost {
-				log.Println("[API] Invalid method on /CreateProduct")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
 
-			log.Println("[API] Handling product creation")
 			w.Header().Set("Content-Type", "application/json")
 			controllers.CreateProduct(controllers.RequestContext{
-				DB: db,
-				W:  w,
-				R:  r,
+				Products: products,
+				W:        w,
+				R:        r,
 			})
-		},
+		})),
 	)
 	mux.HandleFunc("/GetProducts",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /GetProducts %s request\n", r.Method)
+		deprecated("/v1/products", auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				log.Println("[API] Invalid method on /GetProducts")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
-			log.Println("[API] Fetching products from DB")
 			w.Header().Set("Content-Type", "application/json")
 			controllers.GetProducts(controllers.RequestContext{
-				DB: db,
-				W:  w,
-				R:  r,
+				Products: products,
+				W:        w,
+				R:        r,
 			})
-		},
+		})),
 	)
 
 	mux.HandleFunc("/GetProductByID/",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /GetProductByID %s request\n", r.Method)
+		deprecated("/v1/products/{id}", auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				log.Println("[API] Invalid method on /GetProductByID")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
-			log.Println("[API] Fetching product by ID from DB")
 			w.Header().Set("Content-Type", "application/json")
-			controllers.GetProductByID(controllers.RequestContext{
-				DB: db,
-				W:  w,
-				R:  r,
-			})
-		},
+			// Extract ID from query parameter (?id=5)
+			if id, ok := queryID(w, r); ok {
+				controllers.GetProductByID(controllers.RequestContext{
+					Products: products,
+					W:        w,
+					R:        r,
+				}, id)
+			}
+		})),
 	)
 
 	mux.HandleFunc("/UpdateProduct/",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /UpdateProduct %s request\n", r.Method)
-
+		deprecated("/v1/products/{id}", auth.Require(auth.Authenticated, func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodOptions {
-				log.Println("[API] Preflight request on /UpdateProduct")
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 			if r.Method != http.MethodPut {
-				log.Println("[API] Invalid method on /UpdateProduct")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
 
-			log.Println("[API] Handling product update")
 			w.Header().Set("Content-Type", "application/json")
-			controllers.UpdateProduct(controllers.RequestContext{
-				DB: db,
-				W:  w,
-				R:  r,
-			})
-		},
+			if id, ok := bodyID(w, r); ok {
+				controllers.UpdateProduct(controllers.RequestContext{
+					Products: products,
+					W:        w,
+					R:        r,
+				}, id)
+			}
+		})),
+	)
+
+	mux.HandleFunc("/DeleteProduct",
+		deprecated("/v1/products/{id}", auth.Require(auth.Authenticated, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodDelete {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			if id, ok := queryID(w, r); ok {
+				controllers.DeleteProduct(controllers.RequestContext{
+					Products: products,
+					W:        w,
+					R:        r,
+				}, id)
+			}
+		})),
+	)
+
+	mux.HandleFunc("/RestoreProduct",
+		deprecated("/v1/products/{id}/restore", auth.Require(auth.Authenticated, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			if id, ok := queryID(w, r); ok {
+				controllers.RestoreProduct(controllers.RequestContext{
+					Products: products,
+					W:        w,
+					R:        r,
+				}, id)
+			}
+		})),
 	)
 }
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/repository"
+)
+
+func TestProductOwnership(t *testing.T) {
+	api := newTestAPI(t)
+	owner, ownerToken := api.user("owner", auth.RoleCustomer)
+	_, otherToken := api.user("other", auth.RoleCustomer)
+	_, staffToken := api.user("staff", auth.RoleStaff)
+	_, adminToken := api.user("admin", auth.RoleAdmin)
+
+	callers := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"owner", ownerToken, http.StatusOK},
+		{"other user", otherToken, http.StatusForbidden},
+		{"staff", staffToken, http.StatusOK},
+		{"admin", adminToken, http.StatusOK},
+	}
+	updated := func(p models.Product) bool { return p.Quantity == 5 }
+	deleted := func(p models.Product) bool { return p.DeletedAt != nil }
+	restored := func(p models.Product) bool { return p.DeletedAt == nil }
+	actions := []struct {
+		name    string
+		deleted bool // whether the product must be deleted first
+		request func(id int) (string, string, any)
+		applied func(p models.Product) bool
+	}{
+		{"patch", false, func(id int) (string, string, any) {
+			return "PATCH", path("/v1/products/%d", id), map[string]any{"quantity": 5}
+		}, updated},
+		{"delete", false, func(id int) (string, string, any) {
+			return "DELETE", path("/v1/products/%d", id), nil
+		}, deleted},
+		{"restore", true, func(id int) (string, string, any) {
+			return "POST", path("/v1/products/%d/restore", id), nil
+		}, restored},
+		{"legacy update", false, func(id int) (string, string, any) {
+			return "PUT", "/UpdateProduct/", map[string]any{"id": id, "quantity": 5}
+		}, updated},
+		{"legacy delete", false, func(id int) (string, string, any) {
+			return "DELETE", path("/DeleteProduct?id=%d", id), nil
+		}, deleted},
+		{"legacy restore", true, func(id int) (string, string, any) {
+			return "POST", path("/RestoreProduct?id=%d", id), nil
+		}, restored},
+	}
+
+	for _, action := range actions {
+		for _, caller := range callers {
+			t.Run(action.name+" by "+caller.name, func(t *testing.T) {
+				p := api.product(owner.ID, "Lamp")
+				if action.deleted {
+					if _, err := api.repos.Products.SetDeleted(context.Background(), p.ID, true,
+						repository.Owner{Any: true}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				method, target, body := action.request(p.ID)
+				got := expect(t, api.do(method, target, caller.token, body), caller.status)
+				if caller.status == http.StatusOK && got["user_id"] != float64(owner.ID) {
+					t.Errorf("user_id = %v, want the owner %d", got["user_id"], owner.ID)
+				}
+
+				after, err := api.repos.Products.Get(context.Background(), p.ID, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if applied := action.applied(after); applied != (caller.status == http.StatusOK) {
+					t.Errorf("%s applied = %v after a %d", action.name, applied, caller.status)
+				}
+			})
+
+			t.Run(action.name+" of missing product by "+caller.name, func(t *testing.T) {
+				method, target, body := action.request(999)
+				expect(t, api.do(method, target, caller.token, body), http.StatusNotFound)
+			})
+		}
+	}
+}
+
+func TestCreateProductIgnoresUserID(t *testing.T) {
+	api := newTestAPI(t)
+	me, token := api.user("me", auth.RoleCustomer)
+	victim, _ := api.user("victim", auth.RoleCustomer)
+
+	for _, target := range []string{"/v1/products", "/CreateProduct"} {
+		t.Run(target, func(t *testing.T) {
+			body := expect(t, api.do("POST", target, token, map[string]any{
+				"name": "Kettle", "price": "10.00", "currency": "USD", "quantity": 1, "user_id": victim.ID,
+			}), http.StatusCreated)
+			if body["user_id"] != float64(me.ID) {
+				t.Errorf("user_id = %v, want the caller %d", body["user_id"], me.ID)
+			}
+		})
+	}
+}
+
+func TestLegacyUpdateUserOwnership(t *testing.T) {
+	api := newTestAPI(t)
+	owner, ownerToken := api.user("owner", auth.RoleCustomer)
+	_, otherToken := api.user("other", auth.RoleCustomer)
+	_, adminToken := api.user("admin", auth.RoleAdmin)
+
+	for _, tt := range []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"owner", ownerToken, http.StatusOK},
+		{"other user", otherToken, http.StatusForbidden},
+		{"admin", adminToken, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			expect(t, api.do("PUT", "/UpdateUser", tt.token,
+				map[string]any{"id": owner.ID, "first_name": tt.name}), tt.status)
+			expect(t, api.do("PATCH", path("/v1/users/%d", owner.ID), tt.token,
+				map[string]any{"first_name": tt.name}), tt.status)
+		})
+	}
+}
+
+func TestUserProductsCursorBoundToOwner(t *testing.T) {
+	api := newTestAPI(t)
+	alice, token := api.user("alice", auth.RoleAdmin)
+	bob, _ := api.user("bob", auth.RoleCustomer)
+	for _, owner := range []int{alice.ID, bob.ID} {
+		api.product(owner, "Lamp")
+		api.product(owner, "Desk")
+	}
+
+	body := expect(t, api.do("GET", path("/v1/users/%d/products?limit=1", alice.ID), token, nil), http.StatusOK)
+	cursor, _ := body["next_cursor"].(string)
+	if cursor == "" {
+		t.Fatalf("no next_cursor in %v", body)
+	}
+	expect(t, api.do("GET", path("/v1/users/%d/products?limit=1&cursor=%s", alice.ID, cursor), token, nil), http.StatusOK)
+
+	problem := expect(t, api.do("GET", path("/v1/users/%d/products?limit=1&cursor=%s", bob.ID, cursor), token, nil),
+		http.StatusUnprocessableEntity)
+	errs, _ := problem["errors"].([]any)
+	if len(errs) != 1 || errs[0].(map[string]any)["code"] != "mismatch" {
+		t.Errorf("errors = %v, want a cursor mismatch", problem["errors"])
+	}
+}
+
+func TestCreateProductRequiresPrice(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.user("me", auth.RoleCustomer)
+
+	for _, body := range []map[string]any{
+		{"name": "Kettle", "quantity": 1},
+		{"name": "Kettle", "quantity": 1, "price": nil},
+	} {
+		for _, target := range []string{"/v1/products", "/CreateProduct"} {
+			problem := expect(t, api.do("POST", target, token, body), http.StatusUnprocessableEntity)
+			errs, _ := problem["errors"].([]any)
+			if len(errs) != 1 || errs[0].(map[string]any)["pointer"] != "/price" ||
+				errs[0].(map[string]any)["code"] != "required" {
+				t.Errorf("%s %v: errors = %v, want price required", target, body, problem["errors"])
+			}
+		}
+	}
+	if body := expect(t, api.do("GET", "/v1/products", "", nil), http.StatusOK); len(body["data"].([]any)) != 0 {
+		t.Errorf("products were created: %v", body["data"])
+	}
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"net/http"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/controllers"
+	"github.com/mdarify1337/backend-go/backend/dto"
+)
+
+func RoleRoutes(mux *http.ServeMux, roles auth.RoleRepository) {
+	mux.HandleFunc("GET /v1/users/{id}/roles",
+		auth.Require(auth.Permission(auth.PermRolesManage), func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.GetUserRoles(roles, w, r, id)
+			}
+		}),
+	)
+	mux.HandleFunc("PUT /v1/users/{id}/roles/{role}",
+		auth.Require(auth.Permission(auth.PermRolesManage), func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.GrantRole(roles, w, r, id, r.PathValue("role"))
+			}
+		}),
+	)
+	mux.HandleFunc("DELETE /v1/users/{id}/roles/{role}",
+		auth.Require(auth.Permission(auth.PermRolesManage), func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.RevokeRole(roles, w, r, id, r.PathValue("role"))
+			}
+		}),
+	)
+
+	mux.HandleFunc("/GetUserRoles",
+		deprecated("/v1/users/{id}/roles", auth.Require(auth.Permission(auth.PermRolesManage), func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			if id, ok := queryID(w, r); ok {
+				controllers.GetUserRoles(roles, w, r, id)
+			}
+		})),
+	)
+
+	mux.HandleFunc("/GrantRole",
+		deprecated("/v1/users/{id}/roles/{role}", auth.Require(auth.Permission(auth.PermRolesManage), func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			var req dto.RoleRequest
+			if !controllers.DecodeBody(w, r, &req) {
+				return
+			}
+			controllers.GrantRole(roles, w, r, req.UserID, req.Role)
+		})),
+	)
+
+	mux.HandleFunc("/RevokeRole",
+		deprecated("/v1/users/{id}/roles/{role}", auth.Require(auth.Permission(auth.PermRolesManage), func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+				return
+			}
+			var req dto.RoleRequest
+			if !controllers.DecodeBody(w, r, &req) {
+				return
+			}
+			controllers.RevokeRole(roles, w, r, req.UserID, req.Role)
+		})),
+	)
+}
@@ -1,11 +1,129 @@
 package services
 
 import (
-	"database/sql"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/httpx"
+	"github.com/mdarify1337/backend-go/backend/logging"
+	"github.com/mdarify1337/backend-go/backend/repository"
 )
 
-func RunAllServices(mux *http.ServeMux, db *sql.DB) {
-	UserRoutes(mux, db)
-	ProductRoutes(mux, db)
+func RunAllServices(mux *http.ServeMux, repos repository.Repositories, tokens auth.Sessions) {
+	UserRoutes(mux, repos, tokens)
+	AuthRoutes(mux, repos.Users, tokens)
+	RoleRoutes(mux, repos.Roles)
+	ProductRoutes(mux, repos.Products)
+}
+
+// WithProblems answers unknown routes and disallowed methods with
+// problem+json instead of the ServeMux's plain text replies. It also names
+// the matched route and the caller in the access log. The route comes from
+// httpx.Resolve when it ran; matched requests are then served by mux, which
+// sets their path values.
+func WithProblems(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match := httpx.Lookup(mux, r)
+		if p, ok := auth.PrincipalFrom(r.Context()); ok {
+			logging.Annotate(r.Context(), slog.Int("user_id", p.UserID))
+		}
+		if match.Pattern != "" {
+			logging.Annotate(r.Context(), slog.String("route", match.Pattern))
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Let the mux decide between 404 and 405 and keep its Allow header
+		rec := &statusRecorder{header: http.Header{}}
+		match.Handler.ServeHTTP(rec, r)
+		if allow := rec.header.Get("Allow"); allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		if rec.status == http.StatusMethodNotAllowed {
+			apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
+			return
+		}
+		apperr.Write(w, r, apperr.NotFound("No route matches "+r.URL.Path))
+	})
+}
+
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (s *statusRecorder) Header() http.Header         { return s.header }
+func (s *statusRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (s *statusRecorder) WriteHeader(status int)      { s.status = status }
+
+// deprecated marks a legacy RPC route and points clients at the resource
+// route that replaces it.
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+// pathID parses the {name} wildcard of the matched pattern as an ID.
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || id <= 0 {
+		slog.InfoContext(r.Context(), "invalid path parameter", "name", name, "value", r.PathValue(name))
+		apperr.Write(w, r, apperr.BadRequest("Invalid ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// queryID parses the ?id= parameter used by the legacy routes.
+func queryID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.URL.Query().Get("id")
+	if idStr == "" {
+		apperr.Write(w, r, apperr.BadRequest("Missing ID"))
+		return 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		apperr.Write(w, r, apperr.BadRequest("Invalid ID"))
+		return 0, false
+	}
+	return id, true
+}
+
+// bodyID reads the "id" field of a JSON body, used by the legacy update
+// routes, and leaves the body intact for the controller.
+func bodyID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	httpx.LimitBody(w, r)
+	body, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apperr.Write(w, r, err)
+		return 0, false
+	} else if err != nil {
+		apperr.Write(w, r, apperr.BadRequest("Invalid request payload"))
+		return 0, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		ID int `json:"id"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		apperr.Write(w, r, apperr.BadRequest("Invalid request payload"))
+		return 0, false
+	}
+	if payload.ID == 0 {
+		apperr.Write(w, r, apperr.BadRequest("Missing ID"))
+		return 0, false
+	}
+	return payload.ID, true
 }
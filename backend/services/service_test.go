@@ -0,0 +1,266 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/httpx"
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/password"
+	"github.com/mdarify1337/backend-go/backend/repository"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// testAPI is the full route table over the in-memory stores, wired like
+// serve.go minus the logging, metrics and tracing middleware.
+type testAPI struct {
+	t       *testing.T
+	repos   repository.Repositories
+	tokens  *auth.Service
+	handler http.Handler
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	return newTestAPIOver(t, repository.NewMemory())
+}
+
+// newTestAPIOver is newTestAPI over repos, which may wrap the memory stores.
+func newTestAPIOver(t *testing.T, repos repository.Repositories) *testAPI {
+	t.Helper()
+	issuer, err := auth.NewIssuer("test", time.Minute, "test", []auth.Key{
+		{ID: "test", Alg: auth.AlgHS256, Secret: bytes.Repeat([]byte("k"), 32)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := &auth.Service{
+		Issuer:  issuer,
+		Refresh: auth.NewMemoryRefreshStore(time.Hour),
+		Roles:   repos.Roles,
+	}
+	mux := http.NewServeMux()
+	RunAllServices(mux, repos, tokens)
+	return &testAPI{t: t, repos: repos, tokens: tokens,
+		handler: issuer.Authenticate(WithProblems(mux))}
+}
+
+// testPassword is the password of every user made by user.
+const testPassword = "correct horse 42"
+
+var testPasswordHash, _ = password.Hash(testPassword)
+
+// user stores a user holding role and returns it with an access token.
+func (a *testAPI) user(name, role string) (models.User, string) {
+	a.t.Helper()
+	u := models.User{Username: name, Email: name + "@example.com", Password: testPasswordHash}
+	if err := a.repos.Users.Create(context.Background(), &u, role); err != nil {
+		a.t.Fatal(err)
+	}
+	token, _, err := a.tokens.Issuer.Sign(u.ID, []string{role})
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	return u, token
+}
+
+// product stores a product owned by userID.
+func (a *testAPI) product(userID int, name string) models.Product {
+	a.t.Helper()
+	p := models.Product{UserID: userID, Name: name, Currency: "USD", Quantity: 1}
+	if err := a.repos.Products.Create(context.Background(), &p); err != nil {
+		a.t.Fatal(err)
+	}
+	return p
+}
+
+// do sends a request with body encoded as JSON, or sent as is when it is a
+// string, and token as the bearer token when set.
+func (a *testAPI) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	a.t.Helper()
+	var buf bytes.Buffer
+	switch b := body.(type) {
+	case nil:
+	case string:
+		buf.WriteString(b)
+	default:
+		if err := json.NewEncoder(&buf).Encode(b); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+	r := httptest.NewRequest(method, path, &buf)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	a.handler.ServeHTTP(w, r)
+	return w
+}
+
+// expect fails the test unless w has status, and returns the decoded body.
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int) map[string]any {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body)
+	}
+	var body map[string]any
+	if w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decoding %q: %v", w.Body, err)
+		}
+	}
+	return body
+}
+
+func path(format string, args ...any) string { return fmt.Sprintf(format, args...) }
+
+func TestRepositoryErrorStatuses(t *testing.T) {
+	api := newTestAPI(t)
+	owner, ownerToken := api.user("owner", auth.RoleCustomer)
+	_, otherToken := api.user("other", auth.RoleCustomer)
+	_, adminToken := api.user("admin", auth.RoleAdmin)
+	p := api.product(owner.ID, "Lamp")
+	rename := map[string]any{"name": "Desk lamp"}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   any
+		status int
+	}{
+		{"missing user", "GET", "/v1/users/999", adminToken, nil, http.StatusNotFound},
+		{"missing user update", "PATCH", "/v1/users/999", adminToken, rename, http.StatusNotFound},
+		{"missing user delete", "DELETE", "/v1/users/999", adminToken, nil, http.StatusNotFound},
+		{"missing product", "GET", "/v1/products/999", "", nil, http.StatusNotFound},
+		{"missing product update", "PATCH", "/v1/products/999", ownerToken, rename, http.StatusNotFound},
+		{"not owner update", "PATCH", path("/v1/products/%d", p.ID), otherToken, rename, http.StatusForbidden},
+		{"not owner delete", "DELETE", path("/v1/products/%d", p.ID), otherToken, nil, http.StatusForbidden},
+		{"role for missing user", "PUT", "/v1/users/999/roles/staff", adminToken, nil, http.StatusNotFound},
+		{"unknown role", "PUT", path("/v1/users/%d/roles/wizard", owner.ID), adminToken, nil, http.StatusBadRequest},
+		{"role not held", "DELETE", path("/v1/users/%d/roles/staff", owner.ID), adminToken, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := expect(t, api.do(tt.method, tt.path, tt.token, tt.body), tt.status)
+			if body["status"] != float64(tt.status) {
+				t.Errorf("problem status = %v, want %d", body["status"], tt.status)
+			}
+		})
+	}
+}
+
+func TestCreateUserDuplicate(t *testing.T) {
+	api := newTestAPI(t)
+	signUp := func(username, email string) *httptest.ResponseRecorder {
+		return api.do("POST", "/v1/users", "", map[string]any{
+			"username": username, "email": email, "password": testPassword,
+		})
+	}
+	expect(t, signUp("alice", "alice@example.com"), http.StatusCreated)
+
+	for _, tt := range []struct{ name, username, email, field string }{
+		{"username", "Alice", "other@example.com", "username"},
+		{"email", "bob", "ALICE@example.com", "email"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			body := expect(t, signUp(tt.username, tt.email), http.StatusConflict)
+			errs, _ := body["errors"].([]any)
+			if len(errs) != 1 || errs[0].(map[string]any)["pointer"] != "/"+tt.field {
+				t.Errorf("errors = %v, want one on %s", body["errors"], tt.field)
+			}
+		})
+	}
+}
+
+func TestRoles(t *testing.T) {
+	api := newTestAPI(t)
+	u, token := api.user("carol", auth.RoleCustomer)
+	_, adminToken := api.user("admin", auth.RoleAdmin)
+	roles := path("/v1/users/%d/roles", u.ID)
+
+	expect(t, api.do("GET", roles, token, nil), http.StatusForbidden)
+	// OPTIONS is no way around the requirement of a method-less route
+	expect(t, api.do("OPTIONS", path("/DeleteUser?id=%d", u.ID), "", nil), http.StatusUnauthorized)
+	expect(t, api.do("OPTIONS", path("/DeleteUser?id=%d", u.ID), token, nil), http.StatusForbidden)
+
+	body := expect(t, api.do("PUT", roles+"/staff", adminToken, nil), http.StatusOK)
+	if got := fmt.Sprint(body["roles"]); got != "[customer staff]" {
+		t.Errorf("roles after grant = %s", got)
+	}
+	body = expect(t, api.do("DELETE", roles+"/customer", adminToken, nil), http.StatusOK)
+	if got := fmt.Sprint(body["roles"]); got != "[staff]" {
+		t.Errorf("roles after revoke = %s", got)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	api := newTestAPI(t)
+	expect(t, api.do("POST", "/v1/users", "", map[string]any{
+		"username": "dave", "email": "dave@example.com", "password": testPassword,
+	}), http.StatusCreated)
+
+	expect(t, api.do("POST", "/v1/auth/token", "", map[string]any{
+		"username": "dave@example.com", "password": "wrong password",
+	}), http.StatusUnauthorized)
+	body := expect(t, api.do("POST", "/v1/auth/token", "", map[string]any{
+		"username": "dave@example.com", "password": testPassword,
+	}), http.StatusOK)
+	first, _ := body["refresh_token"].(string)
+	if first == "" || body["access_token"] == "" {
+		t.Fatalf("sign-in response = %v", body)
+	}
+
+	body = expect(t, api.do("POST", "/v1/auth/refresh", "", map[string]any{"refresh_token": first}), http.StatusOK)
+	second, _ := body["refresh_token"].(string)
+
+	// Presenting the rotated token again revokes the whole family
+	expect(t, api.do("POST", "/v1/auth/refresh", "", map[string]any{"refresh_token": first}), http.StatusUnauthorized)
+	expect(t, api.do("POST", "/v1/auth/refresh", "", map[string]any{"refresh_token": second}), http.StatusUnauthorized)
+
+	body = expect(t, api.do("POST", "/v1/auth/token", "", map[string]any{
+		"username": "dave@example.com", "password": testPassword,
+	}), http.StatusOK)
+	third, _ := body["refresh_token"].(string)
+	expect(t, api.do("POST", "/v1/auth/logout", "", map[string]any{"refresh_token": third}), http.StatusNoContent)
+	expect(t, api.do("POST", "/v1/auth/refresh", "", map[string]any{"refresh_token": third}), http.StatusUnauthorized)
+}
+
+func TestBodyTooLarge(t *testing.T) {
+	api := newTestAPI(t)
+	_, token := api.user("gina", auth.RoleCustomer)
+	huge := `{"id": 1, "name": "` + strings.Repeat("x", httpx.MaxBodyBytes) + `"}`
+
+	for _, tt := range []struct{ name, method, path string }{
+		{"decoded body", "POST", "/v1/products"},
+		{"owner from body", "PUT", "/UpdateUser"},
+		{"legacy body id", "PUT", "/UpdateProduct/"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			body := expect(t, api.do(tt.method, tt.path, token, huge), http.StatusRequestEntityTooLarge)
+			if body["type"] != apperr.TypeBase+string(apperr.KindTooLarge) {
+				t.Errorf("type = %v", body["type"])
+			}
+		})
+	}
+}
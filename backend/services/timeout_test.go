@@ -0,0 +1,109 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
+	"github.com/mdarify1337/backend-go/backend/dbctx"
+	"github.com/mdarify1337/backend-go/backend/logging"
+	"github.com/mdarify1337/backend-go/backend/models"
+	"github.com/mdarify1337/backend-go/backend/repository"
+)
+
+// slowProducts delays every read by delay and bounds it with timeouts the
+// way the Postgres store does, so a test can run a request out of time or
+// hang up on it.
+type slowProducts struct {
+	repository.ProductRepository
+	delay    time.Duration
+	timeouts dbctx.Timeouts
+}
+
+func (s slowProducts) List(ctx context.Context, q repository.ProductQuery) (_ []models.Product, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, "products.list")
+	defer done(&err)
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.ProductRepository.List(ctx, q)
+}
+
+func (s slowProducts) Get(ctx context.Context, id int, includeDeleted bool) (_ models.Product, err error) {
+	ctx, done := s.timeouts.ForRead(ctx, "products.get")
+	defer done(&err)
+	if err := s.wait(ctx); err != nil {
+		return models.Product{}, err
+	}
+	return s.ProductRepository.Get(ctx, id, includeDeleted)
+}
+
+func (s slowProducts) wait(ctx context.Context) error {
+	select {
+	case <-time.After(s.delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newSlowAPI(t *testing.T, timeouts dbctx.Timeouts) *testAPI {
+	t.Helper()
+	repos := repository.NewMemory()
+	repos.Products = slowProducts{ProductRepository: repos.Products, delay: time.Second, timeouts: timeouts}
+	api := newTestAPIOver(t, repos)
+	u, _ := api.user("seller", auth.RoleCustomer)
+	api.product(u.ID, "Lamp")
+	return api
+}
+
+func TestReadTimeout(t *testing.T) {
+	api := newSlowAPI(t, dbctx.Timeouts{Read: 20 * time.Millisecond})
+
+	for _, path := range []string{"/v1/products", "/v1/products/1"} {
+		t.Run(path, func(t *testing.T) {
+			w := api.do("GET", path, "", nil)
+			body := expect(t, w, http.StatusGatewayTimeout)
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			if body["type"] != apperr.TypeBase+string(apperr.KindTimeout) {
+				t.Errorf("type = %v", body["type"])
+			}
+		})
+	}
+}
+
+func TestClientClosedRequest(t *testing.T) {
+	api := newSlowAPI(t, dbctx.Timeouts{})
+
+	var logs bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(prev)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	r := httptest.NewRequest("GET", "/v1/products/1", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	logging.AccessLog(api.handler).ServeHTTP(w, r)
+
+	if w.Code != apperr.StatusClientClosedRequest || w.Body.Len() != 0 {
+		t.Errorf("response = %d %q, want 499 with no body", w.Code, w.Body)
+	}
+	for _, want := range []string{`msg="client closed request"`, `msg=request method=GET path=/v1/products/1 status=499`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs lack %s:\n%s", want, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "level=ERROR") {
+		t.Errorf("a client hanging up was logged as an error:\n%s", logs.String())
+	}
+}
@@ -1,115 +1,137 @@
 package services
 
 import (
-	"database/sql"
-	"log"
 	"net/http"
-	"strconv"
 
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/auth"
 	"github.com/mdarify1337/backend-go/backend/controllers"
+	"github.com/mdarify1337/backend-go/backend/repository"
 )
 
-func UserRoutes(mux *http.ServeMux, db *sql.DB) {
-	mux.HandleFunc("/CreateUser",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /CreateUser %s request\n", r.Method)
+func UserRoutes(mux *http.ServeMux, repos repository.Repositories, tokens auth.Sessions) {
+	mux.HandleFunc("GET /v1/users",
+		auth.Require(auth.Permission(auth.PermUsersReadAny), func(w http.ResponseWriter, r *http.Request) {
+			controllers.GetUsers(repos.Users, w, r)
+		}),
+	)
+	mux.HandleFunc("POST /v1/users",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			controllers.CreateUser(repos.Users, w, r)
+		}),
+	)
+	mux.HandleFunc("GET /v1/users/{id}",
+		auth.Require(auth.OwnerOr(auth.PermUsersReadAny, auth.OwnerFromPath("id")), func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.GetUser(repos.Users, w, r, id)
+			}
+		}),
+	)
+	mux.HandleFunc("PATCH /v1/users/{id}",
+		auth.Require(auth.OwnerOr(auth.PermUsersWriteAny, auth.OwnerFromPath("id")), func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.UpdateUser(repos.Users, w, r, id)
+			}
+		}),
+	)
+	mux.HandleFunc("DELETE /v1/users/{id}",
+		auth.Require(auth.Permission(auth.PermUsersDelete), func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.DeleteUser(repos.Users, w, r, id)
+			}
+		}),
+	)
+	mux.HandleFunc("GET /v1/users/{id}/products",
+		auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
+			if id, ok := pathID(w, r, "id"); ok {
+				controllers.GetUserProducts(controllers.RequestContext{
+					Products: repos.Products,
+					W:        w,
+					R:        r,
+				}, id)
+			}
+		}),
+	)
+
+	legacyUserRoutes(mux, repos.Users, tokens)
+}
 
+// legacyUserRoutes keeps the RPC-style routes working for existing clients.
+func legacyUserRoutes(mux *http.ServeMux, users repository.UserRepository, tokens auth.Sessions) {
+	mux.HandleFunc("/CreateUser",
+		deprecated("/v1/users", auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodOptions {
-				log.Println("[API] Preflight request on /CreateUser")
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 			if r.Method != http.MethodPost {
-				log.Println("[API] Invalid method on /CreateUser")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
 
-			log.Println("[API] Handling user creation")
 			w.Header().Set("Content-Type", "application/json")
-			controllers.CreateUser(db, w, r)
-		},
+			controllers.CreateUser(users, w, r)
+		})),
 	)
 	mux.HandleFunc("/GetUsers",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /GetUsers %s request\n", r.Method)
+		deprecated("/v1/users", auth.Require(auth.Permission(auth.PermUsersReadAny), func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				log.Println("[API] Invalid method on /GetUsers")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
-			log.Println("[API] Fetching users from DB")
 			w.Header().Set("Content-Type", "application/json")
-			controllers.GetUsers(db, w, r)
-		},
+			controllers.GetUsers(users, w, r)
+		})),
 	)
 	mux.HandleFunc("/UpdateUser",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /UpdateUser %s request\n", r.Method)
-
+		deprecated("/v1/users/{id}", auth.Require(auth.OwnerOr(auth.PermUsersWriteAny, auth.OwnerFromBody("id")), func(w http.ResponseWriter, r *http.Request) {
 			if r.Method == http.MethodOptions {
-				log.Println("[API] Preflight request on /UpdateUser")
 				w.WriteHeader(http.StatusNoContent)
 				return
 			}
 			if r.Method != http.MethodPut {
-				log.Println("[API] Invalid method on /UpdateUser")
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
 
-			log.Println("[API] Handling user update")
 			w.Header().Set("Content-Type", "application/json")
-			controllers.UpdateUser(db, w, r)
-		},
+			if id, ok := bodyID(w, r); ok {
+				controllers.UpdateUser(users, w, r, id)
+			}
+		})),
 	)
 
 	mux.HandleFunc("/GetUser",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /GetUser %s request\n", r.Method)
+		deprecated("/v1/users/{id}", auth.Require(auth.OwnerOr(auth.PermUsersReadAny, auth.OwnerFromQuery("id")), func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
-			controllers.GetUser(db, w, r)
-		},
+			if id, ok := queryID(w, r); ok {
+				controllers.GetUser(users, w, r, id)
+			}
+		})),
 	)
 
 	mux.HandleFunc("/DeleteUser",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /DeleteUser %s request\n", r.Method)
+		deprecated("/v1/users/{id}", auth.Require(auth.Permission(auth.PermUsersDelete), func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodDelete {
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
 			// Extract user ID from query parameters
-			idStr := r.URL.Query().Get("id")
-			if idStr == "" {
-				http.Error(w, "Missing user ID", http.StatusBadRequest)
-				return
-			}
-			id, err := strconv.Atoi(idStr)
-			if err != nil {
-				http.Error(w, "Invalid user ID", http.StatusBadRequest)
-				return
+			if id, ok := queryID(w, r); ok {
+				controllers.DeleteUser(users, w, r, id)
 			}
-			controllers.DeleteUser(db, w, r, id)
-		},
+		})),
 	)
 
 	mux.HandleFunc("/SignInUser",
-		func(w http.ResponseWriter, r *http.Request) {
-			log.Printf("[API] /SignInUser %s request\n", r.Method)
+		deprecated("/v1/auth/token", auth.Require(auth.Public, func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodPost {
-				http.Error(w, "Method not allowed",
-					http.StatusMethodNotAllowed)
+				apperr.Write(w, r, apperr.MethodNotAllowed("Method not allowed"))
 				return
 			}
-			controllers.SignInUser(db, w, r)
-		})
+			controllers.SignInUser(users, tokens, w, r)
+		})))
 }
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mdarify1337/backend-go/backend/auth"
+)
+
+func TestUserResponsesHaveNoPassword(t *testing.T) {
+	api := newTestAPI(t)
+	_, adminToken := api.user("admin", auth.RoleAdmin) // user 1; create makes user 2
+	signUp := func(name string) map[string]any {
+		return map[string]any{"username": name, "email": name + "@example.com", "password": testPassword}
+	}
+	signIn := map[string]any{"username": "admin@example.com", "password": testPassword}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		status int
+	}{
+		{"create", "POST", "/v1/users", signUp("erin"), http.StatusCreated},
+		{"get", "GET", "/v1/users/1", nil, http.StatusOK},
+		{"list", "GET", "/v1/users", nil, http.StatusOK},
+		{"update", "PATCH", "/v1/users/2", map[string]any{"password": "another pass 7"}, http.StatusOK},
+		{"sign in", "POST", "/v1/auth/token", signIn, http.StatusOK},
+		{"legacy create", "POST", "/CreateUser", signUp("frank"), http.StatusCreated},
+		{"legacy get", "GET", "/GetUser?id=1", nil, http.StatusOK},
+		{"legacy list", "GET", "/GetUsers", nil, http.StatusOK},
+		{"legacy update", "PUT", "/UpdateUser", map[string]any{"id": 2, "password": "another pass 8"}, http.StatusOK},
+		{"legacy sign in", "POST", "/SignInUser", signIn, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := api.do(tt.method, tt.path, adminToken, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			var body any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !mentionsUser(body) {
+				t.Fatalf("response holds no user: %s", w.Body)
+			}
+			if key, ok := findKey(body, "password"); ok {
+				t.Errorf("response has a %q key: %s", key, w.Body)
+			}
+		})
+	}
+}
+
+// findKey reports the first object key anywhere in v containing substr,
+// ignoring case.
+func findKey(v any, substr string) (string, bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if strings.Contains(strings.ToLower(key), substr) {
+				return key, true
+			}
+			if key, ok := findKey(child, substr); ok {
+				return key, true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if key, ok := findKey(child, substr); ok {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// mentionsUser reports whether v holds a user object, so the test cannot
+// pass on an error body.
+func mentionsUser(v any) bool {
+	_, ok := findKey(v, "username")
+	return ok
+}
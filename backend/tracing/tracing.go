@@ -0,0 +1,137 @@
+// Package tracing sets up OpenTelemetry: a tracer provider exporting over
+// OTLP, to stdout or to a file, W3C trace context propagation, and server
+// spans for incoming requests.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mdarify1337/backend-go/backend/httpx"
+)
+
+const (
+	ExporterNone    = "none"
+	ExporterOTLP    = "otlp"
+	ExporterConsole = "console"
+	ExporterFile    = "file"
+)
+
+// Config picks where finished spans go. With ExporterNone spans are still
+// created, so trace IDs keep showing up in logs and error responses.
+// The OTLP exporter takes its endpoint and headers from the standard
+// OTEL_EXPORTER_OTLP_* environment variables.
+type Config struct {
+	ServiceName string
+	Exporter    string
+	File        string
+}
+
+// Setup installs the global tracer provider and propagator described by
+// cfg. Call the returned func on shutdown to flush pending spans.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: failed to build resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{sdktrace.WithResource(res)}
+	var closer io.Closer
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: failed to create OTLP exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	case ExporterConsole:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing: failed to open %s: %w", cfg.File, err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+		closer = f
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	return func(ctx context.Context) error {
+		err := tp.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Middleware continues the trace of an incoming traceparent header, or
+// starts a new one, with a server span named after the route pattern
+// httpx.Resolve found for the request.
+func Middleware(next http.Handler) http.Handler {
+	tracer := otel.Tracer("github.com/mdarify1337/backend-go/backend/tracing")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		// Patterns may start with a method, which the span name adds anyway
+		route := httpx.Route(r.Context())
+		if _, path, ok := strings.Cut(route, " "); ok {
+			route = path
+		}
+		name := r.Method
+		attrs := []trace.SpanStartOption{
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)),
+		}
+		if route != "" {
+			name += " " + route
+			attrs = append(attrs, trace.WithAttributes(semconv.HTTPRoute(route)))
+		}
+		ctx, span := tracer.Start(ctx, name, attrs...)
+		defer span.End()
+
+		rec := httpx.Record(w)
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		status := rec.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
@@ -0,0 +1,206 @@
+// Package validate checks request DTOs against rules declared in their
+// `validate` struct tags and reports every failing field at once.
+//
+// Rules are comma separated:
+//
+//	required   the value must be present and non-zero
+//	omitempty  skip the remaining rules when the value is empty
+//	min=N      minimum length of a string, or minimum of a number
+//	max=N      maximum length of a string, or maximum of a number
+//	email      a single plain email address
+//	password   the password policy, see PasswordPolicy
+//	currency   a three-letter ISO 4217 currency code
+//
+// Numeric types that are not Go numbers, such as money.Amount, take part in
+// min and max by implementing Number.
+//
+// A nil pointer is treated as absent: it only fails `required`. This is what
+// PATCH bodies rely on to leave fields untouched.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mdarify1337/backend-go/backend/apperr"
+	"github.com/mdarify1337/backend-go/backend/money"
+)
+
+// Number is implemented by numeric types that min and max should compare.
+type Number interface {
+	Float64() float64
+}
+
+// PasswordPolicy is enforced by the password rule.
+var PasswordPolicy = struct {
+	MinLength int
+	MaxLength int
+}{MinLength: 8, MaxLength: 128}
+
+// Struct validates v, a struct or pointer to one. It returns nil or an
+// *apperr.Error of kind validation listing every failing field.
+func Struct(v any) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: Struct called with %T", v))
+	}
+
+	var errs []apperr.FieldError
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		if fe, ok := checkField(pointer(field), rv.Field(i), tag); !ok {
+			errs = append(errs, fe)
+		}
+	}
+	if len(errs) > 0 {
+		return apperr.Invalid(errs)
+	}
+	return nil
+}
+
+// checkField applies the rules in tag to value and stops at the first
+// failure, so each field reports at most one error.
+func checkField(ptr string, value reflect.Value, tag string) (apperr.FieldError, bool) {
+	rules := strings.Split(tag, ",")
+
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			for _, rule := range rules {
+				if rule == "required" {
+					return apperr.FieldError{Pointer: ptr, Code: "required", Detail: "is required"}, false
+				}
+			}
+			return apperr.FieldError{}, true
+		}
+		value = value.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if isEmpty(value) {
+				return apperr.FieldError{Pointer: ptr, Code: name, Detail: "is required"}, false
+			}
+		case "omitempty":
+			if isEmpty(value) {
+				return apperr.FieldError{}, true
+			}
+		case "min", "max":
+			if detail, ok := checkBound(name, param, value); !ok {
+				return apperr.FieldError{Pointer: ptr, Code: name, Detail: detail}, false
+			}
+		case "email":
+			if !isEmail(value.String()) {
+				return apperr.FieldError{Pointer: ptr, Code: name,
+					Detail: "must be a valid email address"}, false
+			}
+		case "password":
+			if detail, ok := checkPassword(value.String()); !ok {
+				return apperr.FieldError{Pointer: ptr, Code: name, Detail: detail}, false
+			}
+		case "currency":
+			if !money.ValidCurrency(value.String()) {
+				return apperr.FieldError{Pointer: ptr, Code: name,
+					Detail: "must be a three-letter ISO 4217 currency code"}, false
+			}
+		default:
+			panic(fmt.Sprintf("validate: unknown rule %q", name))
+		}
+	}
+	return apperr.FieldError{}, true
+}
+
+func checkBound(rule, param string, value reflect.Value) (string, bool) {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: bad %s parameter %q", rule, param))
+	}
+
+	var n float64
+	unit := ""
+	if num, ok := value.Interface().(Number); ok {
+		n = num.Float64()
+	} else {
+		switch value.Kind() {
+		case reflect.String:
+			n = float64(utf8.RuneCountInString(value.String()))
+			unit = " characters"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n = float64(value.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			n = float64(value.Uint())
+		case reflect.Float32, reflect.Float64:
+			n = value.Float()
+		case reflect.Slice, reflect.Map:
+			n = float64(value.Len())
+			unit = " items"
+		default:
+			panic(fmt.Sprintf("validate: %s does not apply to %s", rule, value.Kind()))
+		}
+	}
+
+	if rule == "min" && n < limit {
+		return fmt.Sprintf("must be at least %s%s", param, unit), false
+	}
+	if rule == "max" && n > limit {
+		return fmt.Sprintf("must be at most %s%s", param, unit), false
+	}
+	return "", true
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return false
+	}
+	_, domain, _ := strings.Cut(s, "@")
+	return strings.Contains(domain, ".")
+}
+
+func checkPassword(s string) (string, bool) {
+	n := utf8.RuneCountInString(s)
+	if n < PasswordPolicy.MinLength {
+		return fmt.Sprintf("must be at least %d characters", PasswordPolicy.MinLength), false
+	}
+	if n > PasswordPolicy.MaxLength {
+		return fmt.Sprintf("must be at most %d characters", PasswordPolicy.MaxLength), false
+	}
+	var letter, digit bool
+	for _, r := range s {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		return "must contain at least one letter and one digit", false
+	}
+	return "", true
+}
+
+func isEmpty(value reflect.Value) bool {
+	if value.Kind() == reflect.String {
+		return strings.TrimSpace(value.String()) == ""
+	}
+	return value.IsZero()
+}
+
+// pointer builds the JSON pointer of a field from its json tag.
+func pointer(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		name = field.Name
+	}
+	name = strings.ReplaceAll(name, "~", "~0")
+	name = strings.ReplaceAll(name, "/", "~1")
+	return "/" + name
+}